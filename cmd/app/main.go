@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"image-previewer/internal"
+	"os"
 
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
@@ -13,6 +14,7 @@ import (
 const (
 	EnvDevelopment = "dev"
 	EnvProduction  = "prod"
+	CommandSign    = "sign"
 )
 
 func main() {
@@ -20,6 +22,12 @@ func main() {
 		panic(fmt.Sprintf("failed to init configuration: %s", err))
 	}
 
+	if pflag.Arg(0) == CommandSign {
+		sign(pflag.Args()[1:])
+
+		return
+	}
+
 	if err := initLogger(); err != nil {
 		panic(fmt.Sprintf("failed to init logger: %s", err))
 	}
@@ -31,6 +39,24 @@ func main() {
 	}
 }
 
+// sign prints signed versions of the given preview paths, e.g. `app sign /fill/300/200/example.com/a.jpg`.
+func sign(paths []string) {
+	if len(paths) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: app [--config path] sign /{mode}/{width}/{height}/{url}[?options]...")
+		os.Exit(2)
+	}
+
+	for _, path := range paths {
+		signed, err := internal.SignPath(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to sign %s: %s\n", path, err)
+			os.Exit(1)
+		}
+
+		fmt.Println(signed)
+	}
+}
+
 func initLogger() error {
 	var logger *zap.Logger
 	var err error
@@ -6,8 +6,10 @@ require (
 	github.com/disintegration/imaging v1.6.2
 	github.com/golang/mock v1.4.4
 	github.com/gorilla/mux v1.7.4
+	github.com/prometheus/client_golang v1.7.1
 	github.com/spf13/pflag v1.0.5
 	github.com/spf13/viper v1.7.1
 	github.com/stretchr/testify v1.6.1
 	go.uber.org/zap v1.15.0
+	golang.org/x/image v0.0.0-20191009234506-e7c1f5e7dbb8
 )
@@ -2,22 +2,30 @@ package internal
 
 import (
 	"context"
-	"errors"
 	"image-previewer/internal/application/handlers"
 	"image-previewer/internal/infrastructure"
+	"image-previewer/internal/infrastructure/codec"
 	"image-previewer/internal/infrastructure/downloader"
+	"image-previewer/internal/infrastructure/health"
+	"image-previewer/internal/infrastructure/metrics"
+	"image-previewer/internal/infrastructure/processor"
 	"image-previewer/internal/infrastructure/repository"
 	"image-previewer/internal/interfaces/http/controllers"
+	"image-previewer/internal/interfaces/http/middleware"
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
-	"github.com/spf13/viper"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 )
 
+const previewPath = "/{mode:fill|fit|resize|crop|thumbnail}/{width}/{height}/{url:.*}"
+
 type App struct {
 }
 
@@ -26,19 +34,13 @@ func NewApp() *App {
 }
 
 func (app *App) Run() error {
-	cacheDir := viper.GetString("app.preview_cache_dir")
-	capacity := viper.GetInt("app.preview_cache_size")
-
-	if capacity == 0 {
-		return errors.New("invalid config: preview_cache_size should be set")
-	}
-
-	if cacheDir == "" {
-		return errors.New("invalid config: preview_cache_dir should be set")
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
 	}
 
 	signalCh := make(chan os.Signal, 1)
-	signal.Notify(signalCh, os.Interrupt)
+	signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM)
 
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -47,7 +49,7 @@ func (app *App) Run() error {
 		cancel()
 	}()
 
-	if err := serve(ctx, cacheDir, capacity); err != nil {
+	if err := serve(ctx, cfg); err != nil {
 		zap.S().Fatalf("failed to serve: %s", err)
 
 		return err
@@ -56,19 +58,76 @@ func (app *App) Run() error {
 	return nil
 }
 
-func serve(ctx context.Context, cacheDir string, capacity int) (err error) {
-	rep := repository.NewFileStorage(cacheDir, capacity)
-	idResolver := infrastructure.NewImageIDResolver()
-	httpDownloader := downloader.NewHTTPDownloader(downloader.NewHTTPClient(&http.Client{}))
-	queryHandler := handlers.NewImagePreviewQueryHandler(rep, httpDownloader, idResolver)
-	controller := controllers.NewImagePreviewController(queryHandler)
+func serve(ctx context.Context, cfg *config) (err error) {
+	codecs := codec.NewDefaultRegistry()
+	rep := repository.NewFileStorage(cfg.cacheDir, cfg.capacity, cfg.maxBytes)
+
+	if err := rep.Restore(); err != nil {
+		return err
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		metrics.NewCacheCollector(rep),
+	)
+
+	m := metrics.NewMetrics(registry)
+
+	idResolver := infrastructure.NewImageIDResolver(cfg.filterName, cfg.varyHeaders)
+	cfg.headers.Accept = codecs.Accept()
+
+	httpClient := downloader.NewHTTPClient(
+		downloader.NewTimeoutClient(cfg.downloadTimeouts, cfg.sources),
+		cfg.sources,
+		cfg.headers,
+	)
+	httpDownloader := downloader.NewHTTPDownloader(httpClient, cfg.maxSourceBytes, cfg.originTTL)
+	imageProcessor := processor.NewImageProcessor(codecs, cfg.filter, cfg.maxSourcePixels)
+	queryHandler := handlers.NewImagePreviewQueryHandler(
+		metrics.NewPreviewRepository(rep, m),
+		metrics.NewDownloader(httpDownloader, m),
+		metrics.NewImageProcessor(imageProcessor, m),
+		idResolver,
+		cfg.sizes,
+	)
+
+	var signer *infrastructure.URLSigner
+
+	if cfg.signatureKey != "" {
+		signer = infrastructure.NewURLSigner(cfg.signatureKey)
+	}
+
+	controller := controllers.NewImagePreviewController(
+		queryHandler,
+		codecs,
+		cfg.background,
+		signer,
+		controllers.CacheHeaders{
+			MaxAge: cfg.cacheMaxAge,
+			Vary:   cfg.varyHeaders,
+		},
+	)
+
+	shutdown := &health.ShutdownFlag{}
+	healthController := controllers.NewHealthController(
+		health.DirWritable(cfg.cacheDir),
+		health.FreeSpace(cfg.cacheDir, cfg.minFreeDisk),
+		shutdown.Check(),
+	)
 
 	router := mux.NewRouter()
-	router.HandleFunc("/fill/{width}/{height}/{url:.*}", controller.ActionGet)
+	router.Use(middleware.Timeout(cfg.requestTimeout))
+	router.HandleFunc("/healthz", healthController.ActionHealth)
+	router.HandleFunc("/readyz", healthController.ActionReady)
+	router.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	router.HandleFunc("/{signature}"+previewPath, controller.ActionGet)
+	router.HandleFunc(previewPath, controller.ActionGet)
 
 	srv := &http.Server{
 		Addr:    ":8080",
-		Handler: router,
+		Handler: middleware.AccessLog()(router),
 	}
 
 	go func() {
@@ -83,6 +142,10 @@ func serve(ctx context.Context, cacheDir string, capacity int) (err error) {
 
 	zap.S().Info("stopping server")
 
+	// failing readiness first gives the orchestrator a chance to stop routing traffic here
+	shutdown.Set()
+	time.Sleep(cfg.shutdownDelay)
+
 	ctxShutDown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 
 	defer func() {
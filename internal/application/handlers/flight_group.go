@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"image-previewer/internal/domain"
+	"image-previewer/internal/domain/dto"
+	"image-previewer/internal/logging"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+type flightFunc func(ctx context.Context) (*dto.Preview, error)
+
+type flight struct {
+	done    chan struct{}
+	preview *dto.Preview
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+	// ctx collects the access log annotations of the work, such as the upstream status,
+	// they are handed to every waiter
+	ctx context.Context
+	// requestID is the request that started the work, its id is on the log lines of the work
+	requestID string
+}
+
+// flightGroup collapses concurrent renders of the same image into one. Unlike a plain singleflight
+// the shared work is not bound to the first caller: it is cancelled only once every waiter is gone.
+type flightGroup struct {
+	mux     sync.Mutex
+	flights map[domain.ImageID]*flight
+}
+
+func (g *flightGroup) Do(ctx context.Context, id domain.ImageID, fn flightFunc) (*dto.Preview, error) {
+	g.mux.Lock()
+
+	f, exists := g.flights[id]
+	if !exists {
+		f = g.start(ctx, id, fn)
+	} else {
+		logging.FromContext(ctx).Debugf("joining render of %s started by request %s", id, f.requestID)
+		logging.Annotate(ctx, "render_request_id", f.requestID)
+	}
+
+	f.waiters++
+	g.mux.Unlock()
+
+	select {
+	case <-f.done:
+		logging.Merge(ctx, f.ctx)
+
+		return f.preview, f.err
+	case <-ctx.Done():
+		g.leave(id, f)
+
+		return nil, ctx.Err()
+	}
+}
+
+// start must be called with the lock held.
+func (g *flightGroup) start(ctx context.Context, id domain.ImageID, fn flightFunc) *flight {
+	// no deadline either: the work stops once the last waiter leaves, whoever's deadline that was
+	shared := logging.Fork(detachedContext{ctx})
+	workCtx, cancel := context.WithCancel(shared)
+
+	f := &flight{
+		done:      make(chan struct{}),
+		cancel:    cancel,
+		ctx:       shared,
+		requestID: logging.RequestID(ctx),
+	}
+
+	g.flights[id] = f
+
+	go func() {
+		f.preview, f.err = run(workCtx, fn)
+
+		g.mux.Lock()
+		g.forget(id, f)
+		g.mux.Unlock()
+
+		cancel()
+		close(f.done)
+	}()
+
+	return f
+}
+
+// run turns a panic of the work into an error, unlike a request goroutine nothing would recover it
+// and the whole server would go down.
+func run(ctx context.Context, fn flightFunc) (preview *dto.Preview, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logging.FromContext(ctx).Errorf("render panicked: %v\n%s", r, debug.Stack())
+
+			err = fmt.Errorf("render panicked: %v", r)
+		}
+	}()
+
+	return fn(ctx)
+}
+
+func (g *flightGroup) leave(id domain.ImageID, f *flight) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	f.waiters--
+
+	if f.waiters == 0 {
+		// nobody needs the result anymore, later callers start a fresh flight
+		g.forget(id, f)
+		f.cancel()
+	}
+}
+
+// forget must be called with the lock held.
+func (g *flightGroup) forget(id domain.ImageID, f *flight) {
+	if g.flights[id] == f {
+		delete(g.flights, id)
+	}
+}
+
+// detachedContext keeps the values of the first caller, such as its logger, but not its cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (c detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (c detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (c detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{
+		flights: make(map[domain.ImageID]*flight),
+	}
+}
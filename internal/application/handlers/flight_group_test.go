@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"image-previewer/internal/domain"
+	"image-previewer/internal/domain/dto"
+	"image-previewer/internal/logging"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func (g *flightGroup) waiters(id domain.ImageID) int {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	if f, exists := g.flights[id]; exists {
+		return f.waiters
+	}
+
+	return 0
+}
+
+func TestFlightGroup_JoinedCallerFields(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(core).Sugar()
+	leader := logging.NewContext(context.Background(), "leader", logger.With("request_id", "leader"))
+	joiner := logging.NewContext(context.Background(), "joiner", logger.With("request_id", "joiner"))
+
+	g := newFlightGroup()
+	release := make(chan struct{})
+	results := make(chan error, 2)
+
+	do := func(ctx context.Context) {
+		_, err := g.Do(ctx, "test_id", func(ctx context.Context) (*dto.Preview, error) {
+			logging.Annotate(ctx, "upstream_status", 200)
+			<-release
+
+			return &dto.Preview{}, nil
+		})
+		results <- err
+	}
+
+	go do(leader)
+	require.Eventually(t, func() bool { return g.waiters("test_id") == 1 }, time.Second, time.Millisecond)
+
+	go do(joiner)
+	require.Eventually(t, func() bool { return g.waiters("test_id") == 2 }, time.Second, time.Millisecond)
+
+	close(release)
+	require.Nil(t, <-results)
+	require.Nil(t, <-results)
+
+	require.Equal(t, []interface{}{"upstream_status", 200}, logging.Fields(leader))
+	require.Equal(t, []interface{}{"render_request_id", "leader", "upstream_status", 200}, logging.Fields(joiner))
+
+	joins := logs.FilterMessage("joining render of test_id started by request leader").All()
+	require.Len(t, joins, 1)
+	require.Equal(t, "joiner", joins[0].ContextMap()["request_id"])
+}
+
+func TestFlightGroup_Panic(t *testing.T) {
+	g := newFlightGroup()
+
+	preview, err := g.Do(context.Background(), "test_id", func(ctx context.Context) (*dto.Preview, error) {
+		panic("corrupted image")
+	})
+
+	require.Nil(t, preview)
+	require.EqualError(t, err, "render panicked: corrupted image")
+	require.Equal(t, 0, g.waiters("test_id"))
+}
+
+func TestFlightGroup_FirstCallerGone(t *testing.T) {
+	g := newFlightGroup()
+	release := make(chan struct{})
+	results := make(chan error, 1)
+
+	first, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	go func() {
+		_, err := g.Do(first, "test_id", func(ctx context.Context) (*dto.Preview, error) {
+			if _, ok := ctx.Deadline(); ok {
+				return nil, errors.New("work should not inherit the deadline of the first caller")
+			}
+
+			select {
+			case <-release:
+				return &dto.Preview{}, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		})
+		results <- err
+	}()
+
+	require.Eventually(t, func() bool { return g.waiters("test_id") == 1 }, time.Second, time.Millisecond)
+
+	joined := make(chan error, 1)
+
+	go func() {
+		_, err := g.Do(context.Background(), "test_id", nil)
+		joined <- err
+	}()
+
+	require.Eventually(t, func() bool { return g.waiters("test_id") == 2 }, time.Second, time.Millisecond)
+
+	cancel()
+	require.Equal(t, context.Canceled, <-results)
+
+	close(release)
+	require.Nil(t, <-joined)
+}
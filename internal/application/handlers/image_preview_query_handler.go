@@ -1,74 +1,208 @@
 package handlers
 
 import (
+	"context"
+	"crypto/sha256"
 	"errors"
+	"fmt"
 	"image"
 	"image-previewer/internal/application/queries"
 	"image-previewer/internal/domain"
+	"image-previewer/internal/domain/dto"
+	"image-previewer/internal/logging"
 	"net/url"
-
-	"go.uber.org/zap"
+	"time"
 )
 
 var (
-	ErrInvalidWidth  = errors.New("width should be greater than 0")
-	ErrInvalidHeight = errors.New("height should be greater than 0")
-	ErrEmptyURL      = errors.New("url should not be empty")
-	ErrInvalidURL    = errors.New("url should be valid")
-	ErrNotFound      = errors.New("img not found")
+	ErrInvalidMode    = domain.NewError(domain.ErrValidation, "resize mode is not supported")
+	ErrInvalidGravity = domain.NewError(domain.ErrValidation, "gravity is not supported")
+	ErrInvalidWidth   = domain.NewError(domain.ErrValidation, "width should be greater than 0")
+	ErrInvalidHeight  = domain.NewError(domain.ErrValidation, "height should be greater than 0")
+	ErrEmptyURL       = domain.NewError(domain.ErrValidation, "url should not be empty")
+	ErrInvalidURL     = domain.NewError(domain.ErrValidation, "url should be valid")
+	ErrNotFound       = errors.New("img not found")
 )
 
 type ImagePreviewQueryHandler struct {
 	previewRepository domain.PreviewRepository
 	downloader        domain.Downloader
+	processor         domain.ImageProcessor
 	idResolver        domain.ImageIDResolver
+	inflight          *flightGroup
+	sizes             SizePolicy
 }
 
-func (h *ImagePreviewQueryHandler) Handle(q queries.ImagePreviewQuery) (image.Image, error) {
+func (h *ImagePreviewQueryHandler) Handle(ctx context.Context, q queries.ImagePreviewQuery) (*dto.PreviewStream, error) {
 	if err := h.checkQuery(q); err != nil {
 		return nil, err
 	}
 
-	imageID := h.idResolver.ResolveImageID(q.URL, q.Dimensions)
+	imageID := h.idResolver.ResolveImageID(q.URL, q.Headers, q.Transformation, q.Format)
+
+	logger := logging.FromContext(ctx)
+	logger.Debugf("started processing image %s", string(imageID))
 
-	zap.S().Debugf("started processing image %s", string(imageID))
+	stream, err := h.previewRepository.FindOne(ctx, imageID)
 
-	img, err := h.previewRepository.FindOne(imageID)
+	switch {
+	case err == ErrNotFound:
+		logger.Debug("not found in cache, downloading")
+		logging.Annotate(ctx, "cache", "miss")
 
-	if err == ErrNotFound {
-		zap.S().Debug("not found in cache, downloading")
+		return h.fetch(ctx, imageID, q, dto.OriginCache{})
+	case err != nil:
+		return nil, err
+	case stream.Origin.Stale(time.Now()):
+		logger.Debug("cached image is stale, revalidating")
+		logging.Annotate(ctx, "cache", "stale")
+
+		return h.revalidate(ctx, imageID, q, stream)
+	default:
+		logger.Debug("using image from cache")
+		logging.Annotate(ctx, "cache", "hit")
+	}
 
-		img, err = h.downloader.Download(q.URL, q.Dimensions, q.Headers)
+	return stream, nil
+}
 
-		if err != nil {
-			return nil, err
-		}
+// revalidate replaces a stale preview with what the origin says now, an origin that is down or
+// too slow does not make a stale preview any less usable though.
+func (h *ImagePreviewQueryHandler) revalidate(
+	ctx context.Context,
+	imageID domain.ImageID,
+	q queries.ImagePreviewQuery,
+	stale *dto.PreviewStream,
+) (*dto.PreviewStream, error) {
+	stream, err := h.fetch(ctx, imageID, q, stale.Origin)
+
+	if err != nil && (errors.Is(err, domain.ErrUpstreamFailure) || errors.Is(err, domain.ErrUpstreamTimeout)) {
+		logging.FromContext(ctx).Warnf("failed to revalidate %s, serving stale preview: %s", imageID, err)
+
+		return stale, nil
+	}
+
+	stale.Content.Close()
+
+	return stream, err
+}
 
-		zap.S().Debug("adding to repository")
+// fetch collapses concurrent downloads of the same preview, validators of a cached copy
+// turn the download into a revalidation.
+func (h *ImagePreviewQueryHandler) fetch(
+	ctx context.Context,
+	imageID domain.ImageID,
+	q queries.ImagePreviewQuery,
+	cached dto.OriginCache,
+) (*dto.PreviewStream, error) {
+	preview, err := h.inflight.Do(ctx, imageID, func(ctx context.Context) (*dto.Preview, error) {
+		return h.renderAndStore(ctx, imageID, q, cached)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return preview.Stream(), nil
+}
+
+func (h *ImagePreviewQueryHandler) renderAndStore(
+	ctx context.Context,
+	imageID domain.ImageID,
+	q queries.ImagePreviewQuery,
+	cached dto.OriginCache,
+) (*dto.Preview, error) {
+	src, err := h.downloader.Download(ctx, q.URL, q.Headers, cached)
+	if err != nil {
+		return nil, err
+	}
 
-		_, err = h.previewRepository.Add(imageID, img)
+	if src.NotModified {
+		logging.FromContext(ctx).Debug("origin image not modified, keeping cached preview")
 
-		if err != nil {
-			return nil, err
+		preview, err := h.previewRepository.Refresh(ctx, imageID, src.Origin)
+		if err != ErrNotFound {
+			return preview, err
 		}
 
-		return img, nil
+		// evicted since it was found stale, without validators the origin sends the image itself
+		logging.FromContext(ctx).Debug("cached preview is gone, downloading again")
+
+		return h.renderAndStore(ctx, imageID, q, dto.OriginCache{})
 	}
 
-	zap.S().Debug("using image from cache")
+	img, err := h.render(ctx, q, src)
+	if err != nil {
+		return nil, err
+	}
 
-	return img, err
+	preview, err := h.processor.Encode(img, q.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	preview.ETag = previewETag(imageID, preview.Content)
+	preview.ModTime = time.Now().UTC().Truncate(time.Second)
+	preview.Origin = src.Origin
+
+	logging.FromContext(ctx).Debug("adding to repository")
+
+	if _, err = h.previewRepository.Add(ctx, imageID, preview); err != nil {
+		return nil, err
+	}
+
+	return preview, nil
+}
+
+func (h *ImagePreviewQueryHandler) render(
+	ctx context.Context,
+	q queries.ImagePreviewQuery,
+	src *dto.SourceImage,
+) (image.Image, error) {
+	// the client may be gone already, decoding is the expensive part
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	img, err := h.processor.Decode(src, !q.Transformation.IgnoreOrientation)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.processor.Transform(img, q.Transformation)
+}
+
+// previewETag is a strong validator: the id covers everything the preview was rendered from,
+// the content covers rerenders that came out different, e.g. after the origin changed the image.
+func previewETag(id domain.ImageID, content []byte) string {
+	h := sha256.New()
+	_, _ = h.Write([]byte(id))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write(content)
+
+	return fmt.Sprintf(`"%x"`, h.Sum(nil)[:16])
 }
 
 func (h *ImagePreviewQueryHandler) checkQuery(q queries.ImagePreviewQuery) error {
-	if q.Dimensions.Width < 1 {
+	if !q.Transformation.Mode.IsValid() {
+		return ErrInvalidMode
+	}
+
+	if g := q.Transformation.Gravity; g != "" && !g.IsValid() {
+		return ErrInvalidGravity
+	}
+
+	if q.Transformation.Dimensions.Width < 1 {
 		return ErrInvalidWidth
 	}
 
-	if q.Dimensions.Height < 1 {
+	if q.Transformation.Dimensions.Height < 1 {
 		return ErrInvalidHeight
 	}
 
+	if err := h.sizes.Check(q.Transformation.Dimensions); err != nil {
+		return err
+	}
+
 	if q.URL == "" {
 		return ErrEmptyURL
 	}
@@ -83,11 +217,16 @@ func (h *ImagePreviewQueryHandler) checkQuery(q queries.ImagePreviewQuery) error
 func NewImagePreviewQueryHandler(
 	rep domain.PreviewRepository,
 	downloader domain.Downloader,
+	processor domain.ImageProcessor,
 	resolver domain.ImageIDResolver,
+	sizes SizePolicy,
 ) *ImagePreviewQueryHandler {
 	return &ImagePreviewQueryHandler{
 		previewRepository: rep,
 		downloader:        downloader,
+		processor:         processor,
 		idResolver:        resolver,
+		inflight:          newFlightGroup(),
+		sizes:             sizes,
 	}
 }
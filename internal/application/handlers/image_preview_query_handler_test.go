@@ -1,22 +1,30 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"image"
 	"image-previewer/internal/application/queries"
 	"image-previewer/internal/domain"
 	"image-previewer/internal/domain/dto"
+	"image-previewer/internal/logging"
 	"image-previewer/tests/mocks"
 	"image/jpeg"
+	"io/ioutil"
 	"os"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
 )
 
 //go:generate mockgen -destination=../../../tests/mocks/mock_preview_repository.go -package=mocks image-previewer/internal/domain PreviewRepository
 //go:generate mockgen -destination=../../../tests/mocks/mock_downloader.go -package=mocks image-previewer/internal/domain Downloader
 //go:generate mockgen -destination=../../../tests/mocks/mock_id_resolver.go -package=mocks image-previewer/internal/domain ImageIDResolver
+//go:generate mockgen -destination=../../../tests/mocks/mock_image_processor.go -package=mocks image-previewer/internal/domain ImageProcessor
 //nolint:funlen
 func TestImagePreviewQueryHandler_Handle(t *testing.T) {
 	ctrl := gomock.NewController(t)
@@ -25,129 +33,611 @@ func TestImagePreviewQueryHandler_Handle(t *testing.T) {
 		rep := mocks.NewMockPreviewRepository(ctrl)
 		idResolver := mocks.NewMockImageIDResolver(ctrl)
 		downloader := mocks.NewMockDownloader(ctrl)
+		processor := mocks.NewMockImageProcessor(ctrl)
 
-		handler := NewImagePreviewQueryHandler(rep, downloader, idResolver)
+		handler := NewImagePreviewQueryHandler(rep, downloader, processor, idResolver, SizePolicy{})
 
-		img, err := handler.Handle(queries.ImagePreviewQuery{
+		preview, err := handler.Handle(context.Background(), queries.ImagePreviewQuery{
 			URL: "http://ya.ru",
-			Dimensions: dto.ImageDimensions{
-				Width:  0,
-				Height: 200,
+			Transformation: dto.Transformation{
+				Mode: dto.ModeFill,
+				Dimensions: dto.ImageDimensions{
+					Width:  0,
+					Height: 200,
+				},
 			},
 		})
 
-		require.Nil(t, img)
+		require.Nil(t, preview)
 		require.Equal(t, err, ErrInvalidWidth)
 
-		img, err = handler.Handle(queries.ImagePreviewQuery{
+		preview, err = handler.Handle(context.Background(), queries.ImagePreviewQuery{
 			URL: "http://ya.ru",
-			Dimensions: dto.ImageDimensions{
-				Width:  100,
-				Height: 0,
+			Transformation: dto.Transformation{
+				Mode: dto.ModeFill,
+				Dimensions: dto.ImageDimensions{
+					Width:  100,
+					Height: 0,
+				},
 			},
 		})
 
-		require.Nil(t, img)
+		require.Nil(t, preview)
 		require.Equal(t, err, ErrInvalidHeight)
 	})
 
+	t.Run("size outside policy", func(t *testing.T) {
+		rep := mocks.NewMockPreviewRepository(ctrl)
+		idResolver := mocks.NewMockImageIDResolver(ctrl)
+		downloader := mocks.NewMockDownloader(ctrl)
+		processor := mocks.NewMockImageProcessor(ctrl)
+
+		handler := NewImagePreviewQueryHandler(rep, downloader, processor, idResolver, SizePolicy{MaxWidth: 1000})
+
+		preview, err := handler.Handle(context.Background(), queries.ImagePreviewQuery{
+			URL: "http://ya.ru",
+			Transformation: dto.Transformation{
+				Mode: dto.ModeFill,
+				Dimensions: dto.ImageDimensions{
+					Width:  50000,
+					Height: 200,
+				},
+			},
+		})
+
+		require.Nil(t, preview)
+		require.Equal(t, ErrWidthTooLarge, err)
+	})
+
+	t.Run("invalid query mode and gravity", func(t *testing.T) {
+		rep := mocks.NewMockPreviewRepository(ctrl)
+		idResolver := mocks.NewMockImageIDResolver(ctrl)
+		downloader := mocks.NewMockDownloader(ctrl)
+		processor := mocks.NewMockImageProcessor(ctrl)
+
+		handler := NewImagePreviewQueryHandler(rep, downloader, processor, idResolver, SizePolicy{})
+
+		preview, err := handler.Handle(context.Background(), queries.ImagePreviewQuery{
+			URL: "http://ya.ru",
+			Transformation: dto.Transformation{
+				Mode: dto.ResizeMode("stretch"),
+				Dimensions: dto.ImageDimensions{
+					Width:  100,
+					Height: 100,
+				},
+			},
+		})
+
+		require.Nil(t, preview)
+		require.Equal(t, err, ErrInvalidMode)
+
+		preview, err = handler.Handle(context.Background(), queries.ImagePreviewQuery{
+			URL: "http://ya.ru",
+			Transformation: dto.Transformation{
+				Mode:    dto.ModeFill,
+				Gravity: dto.Gravity("up"),
+				Dimensions: dto.ImageDimensions{
+					Width:  100,
+					Height: 100,
+				},
+			},
+		})
+
+		require.Nil(t, preview)
+		require.Equal(t, err, ErrInvalidGravity)
+	})
+
 	t.Run("invalid query url", func(t *testing.T) {
 		rep := mocks.NewMockPreviewRepository(ctrl)
 		idResolver := mocks.NewMockImageIDResolver(ctrl)
 		downloader := mocks.NewMockDownloader(ctrl)
+		processor := mocks.NewMockImageProcessor(ctrl)
 
-		handler := NewImagePreviewQueryHandler(rep, downloader, idResolver)
+		handler := NewImagePreviewQueryHandler(rep, downloader, processor, idResolver, SizePolicy{})
 
-		img, err := handler.Handle(queries.ImagePreviewQuery{
+		preview, err := handler.Handle(context.Background(), queries.ImagePreviewQuery{
 			URL: "",
-			Dimensions: dto.ImageDimensions{
-				Width:  100,
-				Height: 100,
+			Transformation: dto.Transformation{
+				Mode: dto.ModeFill,
+				Dimensions: dto.ImageDimensions{
+					Width:  100,
+					Height: 100,
+				},
 			},
 		})
 
-		require.Nil(t, img)
+		require.Nil(t, preview)
 		require.Equal(t, err, ErrEmptyURL)
 	})
 
 	t.Run("image found in repository", func(t *testing.T) {
+		expectedStream := fakedPreview().Stream()
+
 		rep := mocks.NewMockPreviewRepository(ctrl)
 		rep.
 			EXPECT().
-			FindOne(gomock.Any()).
-			Return(fakedImg(), nil)
+			FindOne(gomock.Any(), gomock.Any()).
+			Return(expectedStream, nil)
 		rep.
 			EXPECT().
-			Add(gomock.Any(), gomock.Any()).
+			Add(gomock.Any(), gomock.Any(), gomock.Any()).
 			Times(0)
 
 		idResolver := mocks.NewMockImageIDResolver(ctrl)
 		idResolver.
 			EXPECT().
-			ResolveImageID(gomock.Any(), gomock.Any()).
+			ResolveImageID(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 			Return(domain.ImageID("test_id"))
 
 		downloader := mocks.NewMockDownloader(ctrl)
 		downloader.
 			EXPECT().
-			Download(gomock.Any(), gomock.Any(), gomock.Any()).
+			Download(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			Times(0)
+
+		processor := mocks.NewMockImageProcessor(ctrl)
+		processor.
+			EXPECT().
+			Decode(gomock.Any(), gomock.Any()).
+			Times(0)
+		processor.
+			EXPECT().
+			Encode(gomock.Any(), gomock.Any()).
 			Times(0)
 
-		handler := NewImagePreviewQueryHandler(rep, downloader, idResolver)
+		handler := NewImagePreviewQueryHandler(rep, downloader, processor, idResolver, SizePolicy{})
 
-		img, err := handler.Handle(queries.ImagePreviewQuery{
+		preview, err := handler.Handle(context.Background(), queries.ImagePreviewQuery{
 			URL: "http://ya.ru",
-			Dimensions: dto.ImageDimensions{
-				Width:  100,
-				Height: 200,
+			Transformation: dto.Transformation{
+				Mode: dto.ModeFill,
+				Dimensions: dto.ImageDimensions{
+					Width:  100,
+					Height: 200,
+				},
 			},
+			Format: dto.FormatJPEG,
 		})
 
 		require.Nil(t, err)
-		require.NotNil(t, img)
+		require.Same(t, expectedStream, preview)
 	})
 
 	t.Run("image not found in repository", func(t *testing.T) {
+		expectedPreview := fakedPreview()
+
 		rep := mocks.NewMockPreviewRepository(ctrl)
 		rep.
 			EXPECT().
-			FindOne(gomock.Any()).
+			FindOne(gomock.Any(), gomock.Any()).
 			Return(nil, ErrNotFound)
 		rep.
 			EXPECT().
-			Add(gomock.Any(), gomock.Any()).
-			Return(true, nil).
+			Add(gomock.Any(), domain.ImageID("test_id"), expectedPreview).
+			Return(false, nil).
 			Times(1)
 
 		idResolver := mocks.NewMockImageIDResolver(ctrl)
 		idResolver.
 			EXPECT().
-			ResolveImageID(gomock.Any(), gomock.Any()).
+			ResolveImageID(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 			Return(domain.ImageID("test_id"))
 
-		actualImg := fakedImg()
+		src := &dto.SourceImage{}
+		decodedImg := fakedImg()
+		transformedImg := fakedImg()
 
 		downloader := mocks.NewMockDownloader(ctrl)
 		downloader.
 			EXPECT().
-			Download(gomock.Any(), gomock.Any(), gomock.Any()).
-			Return(actualImg, nil).
+			Download(gomock.Any(), "http://ya.ru", gomock.Any(), dto.OriginCache{}).
+			Return(src, nil).
 			Times(1)
 
-		handler := NewImagePreviewQueryHandler(rep, downloader, idResolver)
+		processor := mocks.NewMockImageProcessor(ctrl)
+		processor.
+			EXPECT().
+			Decode(src, true).
+			Return(decodedImg, nil)
+		processor.
+			EXPECT().
+			Transform(decodedImg, gomock.Any()).
+			Return(transformedImg, nil)
+		processor.
+			EXPECT().
+			Encode(transformedImg, dto.FormatJPEG).
+			Return(expectedPreview, nil)
 
-		img, err := handler.Handle(queries.ImagePreviewQuery{
+		handler := NewImagePreviewQueryHandler(rep, downloader, processor, idResolver, SizePolicy{})
+
+		preview, err := handler.Handle(context.Background(), queries.ImagePreviewQuery{
 			URL: "http://ya.ru",
+			Transformation: dto.Transformation{
+				Mode: dto.ModeFill,
+				Dimensions: dto.ImageDimensions{
+					Width:  100,
+					Height: 200,
+				},
+			},
+			Format: dto.FormatJPEG,
+		})
+
+		require.Nil(t, err)
+		require.Equal(t, previewETag("test_id", expectedPreview.Content), preview.ETag)
+		require.False(t, preview.ModTime.IsZero())
+		require.Equal(t, expectedPreview.Content, readAll(t, preview))
+	})
+}
+
+//nolint:funlen
+func TestImagePreviewQueryHandler_HandleStale(t *testing.T) {
+	query := queries.ImagePreviewQuery{
+		URL: "http://ya.ru",
+		Transformation: dto.Transformation{
+			Mode: dto.ModeFill,
 			Dimensions: dto.ImageDimensions{
 				Width:  100,
 				Height: 200,
 			},
-		})
+		},
+		Format: dto.FormatJPEG,
+	}
+	cached := dto.OriginCache{ETag: `"v1"`, Expires: time.Now().Add(-time.Minute)}
+
+	staleStream := func() *dto.PreviewStream {
+		stream := fakedPreview().Stream()
+		stream.Origin = cached
+
+		return stream
+	}
+
+	t.Run("fresh entry is served without asking the origin", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		stream := fakedPreview().Stream()
+		stream.Origin.Expires = time.Now().Add(time.Hour)
+
+		rep := mocks.NewMockPreviewRepository(ctrl)
+		rep.EXPECT().FindOne(gomock.Any(), domain.ImageID("test_id")).Return(stream, nil)
+
+		idResolver := mocks.NewMockImageIDResolver(ctrl)
+		idResolver.EXPECT().ResolveImageID(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(domain.ImageID("test_id"))
+
+		handler := NewImagePreviewQueryHandler(rep, mocks.NewMockDownloader(ctrl), mocks.NewMockImageProcessor(ctrl), idResolver, SizePolicy{})
+
+		preview, err := handler.Handle(context.Background(), query)
 
 		require.Nil(t, err)
-		require.NotNil(t, img)
-		require.Same(t, img, actualImg)
+		require.Same(t, stream, preview)
 	})
+
+	t.Run("unchanged origin refreshes the entry", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		refreshed := dto.OriginCache{ETag: `"v1"`, Expires: time.Now().Add(time.Hour)}
+		expectedPreview := fakedPreview()
+
+		rep := mocks.NewMockPreviewRepository(ctrl)
+		rep.EXPECT().FindOne(gomock.Any(), domain.ImageID("test_id")).Return(staleStream(), nil)
+		rep.EXPECT().Refresh(gomock.Any(), domain.ImageID("test_id"), refreshed).Return(expectedPreview, nil)
+
+		idResolver := mocks.NewMockImageIDResolver(ctrl)
+		idResolver.EXPECT().ResolveImageID(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(domain.ImageID("test_id"))
+
+		logger := zap.NewNop().Sugar()
+		ctx := logging.NewContext(context.Background(), "test", logger)
+
+		downloader := mocks.NewMockDownloader(ctrl)
+		downloader.
+			EXPECT().
+			Download(gomock.Any(), "http://ya.ru", gomock.Any(), cached).
+			DoAndReturn(func(ctx context.Context, _ string, _ domain.RequestHeaders, _ dto.OriginCache) (*dto.SourceImage, error) {
+				// the shared download outlives the request but keeps its logger
+				require.Same(t, logger, logging.FromContext(ctx))
+
+				return &dto.SourceImage{NotModified: true, Origin: refreshed}, nil
+			})
+
+		handler := NewImagePreviewQueryHandler(rep, downloader, mocks.NewMockImageProcessor(ctrl), idResolver, SizePolicy{})
+
+		preview, err := handler.Handle(ctx, query)
+
+		require.Nil(t, err)
+		require.Equal(t, expectedPreview.Content, readAll(t, preview))
+		require.Equal(t, []interface{}{"cache", "stale"}, logging.Fields(ctx))
+	})
+
+	t.Run("unavailable origin serves the stale entry", func(t *testing.T) {
+		for _, downloadErr := range []error{
+			&domain.UpstreamError{StatusCode: 503, Err: domain.ErrUpstreamFailure},
+			domain.NewError(domain.ErrUpstreamTimeout, "timed out"),
+			domain.NewError(domain.ErrUpstreamNotFound, "gone"),
+		} {
+			ctrl := gomock.NewController(t)
+			stale := staleStream()
+
+			rep := mocks.NewMockPreviewRepository(ctrl)
+			rep.EXPECT().FindOne(gomock.Any(), domain.ImageID("test_id")).Return(stale, nil)
+
+			idResolver := mocks.NewMockImageIDResolver(ctrl)
+			idResolver.EXPECT().ResolveImageID(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(domain.ImageID("test_id"))
+
+			downloader := mocks.NewMockDownloader(ctrl)
+			downloader.EXPECT().Download(gomock.Any(), "http://ya.ru", gomock.Any(), cached).Return(nil, downloadErr)
+
+			handler := NewImagePreviewQueryHandler(rep, downloader, mocks.NewMockImageProcessor(ctrl), idResolver, SizePolicy{})
+
+			preview, err := handler.Handle(context.Background(), query)
+
+			if errors.Is(downloadErr, domain.ErrUpstreamNotFound) {
+				require.Nil(t, preview)
+				require.Same(t, downloadErr, err)
+			} else {
+				require.Nil(t, err)
+				require.Same(t, stale, preview)
+				require.Equal(t, fakedPreview().Content, readAll(t, preview))
+			}
+
+			ctrl.Finish()
+		}
+	})
+
+	t.Run("changed origin replaces the entry", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		src := &dto.SourceImage{Content: []byte("v2"), Origin: dto.OriginCache{ETag: `"v2"`}}
+		expectedPreview := fakedPreview()
+
+		rep := mocks.NewMockPreviewRepository(ctrl)
+		rep.EXPECT().FindOne(gomock.Any(), domain.ImageID("test_id")).Return(staleStream(), nil)
+		rep.EXPECT().Add(gomock.Any(), domain.ImageID("test_id"), expectedPreview).Return(true, nil)
+
+		idResolver := mocks.NewMockImageIDResolver(ctrl)
+		idResolver.EXPECT().ResolveImageID(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(domain.ImageID("test_id"))
+
+		downloader := mocks.NewMockDownloader(ctrl)
+		downloader.EXPECT().Download(gomock.Any(), "http://ya.ru", gomock.Any(), cached).Return(src, nil)
+
+		processor := mocks.NewMockImageProcessor(ctrl)
+		processor.EXPECT().Decode(src, true).Return(fakedImg(), nil)
+		processor.EXPECT().Transform(gomock.Any(), gomock.Any()).Return(fakedImg(), nil)
+		processor.EXPECT().Encode(gomock.Any(), dto.FormatJPEG).Return(expectedPreview, nil)
+
+		handler := NewImagePreviewQueryHandler(rep, downloader, processor, idResolver, SizePolicy{})
+
+		preview, err := handler.Handle(context.Background(), query)
+
+		require.Nil(t, err)
+		require.Equal(t, src.Origin, preview.Origin)
+		require.Equal(t, expectedPreview.Content, readAll(t, preview))
+	})
+
+	t.Run("entry evicted before refresh is downloaded again", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		refreshed := dto.OriginCache{ETag: `"v1"`, Expires: time.Now().Add(time.Hour)}
+		src := &dto.SourceImage{Content: []byte("v1"), Origin: refreshed}
+		expectedPreview := fakedPreview()
+
+		rep := mocks.NewMockPreviewRepository(ctrl)
+		rep.EXPECT().FindOne(gomock.Any(), domain.ImageID("test_id")).Return(staleStream(), nil)
+		rep.EXPECT().Refresh(gomock.Any(), domain.ImageID("test_id"), refreshed).Return(nil, ErrNotFound)
+		rep.EXPECT().Add(gomock.Any(), domain.ImageID("test_id"), expectedPreview).Return(false, nil)
+
+		idResolver := mocks.NewMockImageIDResolver(ctrl)
+		idResolver.EXPECT().ResolveImageID(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(domain.ImageID("test_id"))
+
+		downloader := mocks.NewMockDownloader(ctrl)
+		gomock.InOrder(
+			downloader.EXPECT().Download(gomock.Any(), "http://ya.ru", gomock.Any(), cached).
+				Return(&dto.SourceImage{NotModified: true, Origin: refreshed}, nil),
+			downloader.EXPECT().Download(gomock.Any(), "http://ya.ru", gomock.Any(), dto.OriginCache{}).
+				Return(src, nil),
+		)
+
+		processor := mocks.NewMockImageProcessor(ctrl)
+		processor.EXPECT().Decode(src, true).Return(fakedImg(), nil)
+		processor.EXPECT().Transform(gomock.Any(), gomock.Any()).Return(fakedImg(), nil)
+		processor.EXPECT().Encode(gomock.Any(), dto.FormatJPEG).Return(expectedPreview, nil)
+
+		handler := NewImagePreviewQueryHandler(rep, downloader, processor, idResolver, SizePolicy{})
+
+		preview, err := handler.Handle(context.Background(), query)
+
+		require.Nil(t, err)
+		require.Equal(t, expectedPreview.Content, readAll(t, preview))
+	})
+}
+
+//nolint:funlen
+func TestImagePreviewQueryHandler_HandleConcurrent(t *testing.T) {
+	query := queries.ImagePreviewQuery{
+		URL: "http://ya.ru",
+		Transformation: dto.Transformation{
+			Mode: dto.ModeFill,
+			Dimensions: dto.ImageDimensions{
+				Width:  100,
+				Height: 200,
+			},
+		},
+		Format: dto.FormatJPEG,
+	}
+
+	t.Run("concurrent misses share one download", func(t *testing.T) {
+		const callers = 20
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		release := make(chan struct{})
+		expectedPreview := fakedPreview()
+
+		rep := mocks.NewMockPreviewRepository(ctrl)
+		rep.EXPECT().FindOne(gomock.Any(), gomock.Any()).Return(nil, ErrNotFound).Times(callers)
+		rep.EXPECT().Add(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+
+		idResolver := mocks.NewMockImageIDResolver(ctrl)
+		idResolver.EXPECT().ResolveImageID(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(domain.ImageID("test_id")).Times(callers)
+
+		downloader := mocks.NewMockDownloader(ctrl)
+		downloader.
+			EXPECT().
+			Download(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(context.Context, string, domain.RequestHeaders, dto.OriginCache) (*dto.SourceImage, error) {
+				<-release
+
+				return &dto.SourceImage{}, nil
+			}).
+			Times(1)
+
+		processor := mocks.NewMockImageProcessor(ctrl)
+		processor.EXPECT().Decode(gomock.Any(), gomock.Any()).Return(fakedImg(), nil).Times(1)
+		processor.EXPECT().Transform(gomock.Any(), gomock.Any()).Return(fakedImg(), nil).Times(1)
+		processor.EXPECT().Encode(gomock.Any(), gomock.Any()).Return(expectedPreview, nil).Times(1)
+
+		handler := NewImagePreviewQueryHandler(rep, downloader, processor, idResolver, SizePolicy{})
+
+		var wg sync.WaitGroup
+
+		previews := make([]*dto.PreviewStream, callers)
+		errs := make([]error, callers)
+
+		for i := 0; i < callers; i++ {
+			wg.Add(1)
+
+			go func(i int) {
+				defer wg.Done()
+
+				previews[i], errs[i] = handler.Handle(context.Background(), query)
+			}(i)
+		}
+
+		require.Eventually(t, func() bool {
+			return handler.inflight.waiters("test_id") == callers
+		}, time.Second, time.Millisecond)
+
+		close(release)
+		wg.Wait()
+
+		for i := 0; i < callers; i++ {
+			require.Nil(t, errs[i])
+			require.Equal(t, expectedPreview.Content, readAll(t, previews[i]))
+		}
+	})
+
+	t.Run("waiters share the error", func(t *testing.T) {
+		const callers = 5
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		release := make(chan struct{})
+
+		rep := mocks.NewMockPreviewRepository(ctrl)
+		rep.EXPECT().FindOne(gomock.Any(), gomock.Any()).Return(nil, ErrNotFound).Times(callers)
+		rep.EXPECT().Add(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+		idResolver := mocks.NewMockImageIDResolver(ctrl)
+		idResolver.EXPECT().ResolveImageID(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(domain.ImageID("test_id")).Times(callers)
+
+		downloadErr := errors.New("image resource unavailable")
+
+		downloader := mocks.NewMockDownloader(ctrl)
+		downloader.
+			EXPECT().
+			Download(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(context.Context, string, domain.RequestHeaders, dto.OriginCache) (*dto.SourceImage, error) {
+				<-release
+
+				return nil, downloadErr
+			}).
+			Times(1)
+
+		handler := NewImagePreviewQueryHandler(rep, downloader, mocks.NewMockImageProcessor(ctrl), idResolver, SizePolicy{})
+
+		var wg sync.WaitGroup
+
+		errs := make([]error, callers)
+
+		for i := 0; i < callers; i++ {
+			wg.Add(1)
+
+			go func(i int) {
+				defer wg.Done()
+
+				_, errs[i] = handler.Handle(context.Background(), query)
+			}(i)
+		}
+
+		require.Eventually(t, func() bool {
+			return handler.inflight.waiters("test_id") == callers
+		}, time.Second, time.Millisecond)
+
+		close(release)
+		wg.Wait()
+
+		for i := 0; i < callers; i++ {
+			require.Same(t, downloadErr, errs[i])
+		}
+	})
+
+	t.Run("work is cancelled when every caller is gone", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		workCancelled := make(chan struct{})
+
+		rep := mocks.NewMockPreviewRepository(ctrl)
+		rep.EXPECT().FindOne(gomock.Any(), gomock.Any()).Return(nil, ErrNotFound)
+
+		idResolver := mocks.NewMockImageIDResolver(ctrl)
+		idResolver.EXPECT().ResolveImageID(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(domain.ImageID("test_id"))
+
+		downloader := mocks.NewMockDownloader(ctrl)
+		downloader.
+			EXPECT().
+			Download(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, _ string, _ domain.RequestHeaders, _ dto.OriginCache) (*dto.SourceImage, error) {
+				<-ctx.Done()
+				close(workCancelled)
+
+				return nil, ctx.Err()
+			})
+
+		handler := NewImagePreviewQueryHandler(rep, downloader, mocks.NewMockImageProcessor(ctrl), idResolver, SizePolicy{})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		preview, err := handler.Handle(ctx, query)
+
+		require.Nil(t, preview)
+		require.Equal(t, context.DeadlineExceeded, err)
+
+		select {
+		case <-workCancelled:
+		case <-time.After(time.Second):
+			require.Fail(t, "download was not cancelled")
+		}
+	})
+}
+
+func fakedPreview() *dto.Preview {
+	return &dto.Preview{
+		Content:     []byte("preview"),
+		ContentType: "image/jpeg",
+	}
+}
+
+func readAll(t *testing.T, stream *dto.PreviewStream) []byte {
+	defer stream.Content.Close()
+
+	content, err := ioutil.ReadAll(stream.Content)
+	require.Nil(t, err)
+	require.Equal(t, int64(len(content)), stream.Size)
+
+	return content
 }
 
 func fakedImg() image.Image {
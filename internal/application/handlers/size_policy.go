@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"image-previewer/internal/domain"
+	"image-previewer/internal/domain/dto"
+)
+
+var (
+	ErrWidthTooLarge  = domain.NewError(domain.ErrValidation, "width exceeds the allowed maximum")
+	ErrHeightTooLarge = domain.NewError(domain.ErrValidation, "height exceeds the allowed maximum")
+	ErrSizeNotAllowed = domain.NewError(domain.ErrValidation, "requested size is not in the allowed list")
+)
+
+// SizePolicy bounds the output dimensions so the cache can't be flooded with arbitrary variants.
+// Zero limits and an empty preset list mean no restriction.
+type SizePolicy struct {
+	MaxWidth  int
+	MaxHeight int
+	Presets   []dto.ImageDimensions
+}
+
+func (p SizePolicy) Check(d dto.ImageDimensions) error {
+	if p.MaxWidth > 0 && d.Width > p.MaxWidth {
+		return ErrWidthTooLarge
+	}
+
+	if p.MaxHeight > 0 && d.Height > p.MaxHeight {
+		return ErrHeightTooLarge
+	}
+
+	if len(p.Presets) == 0 {
+		return nil
+	}
+
+	for _, preset := range p.Presets {
+		if preset == d {
+			return nil
+		}
+	}
+
+	return ErrSizeNotAllowed
+}
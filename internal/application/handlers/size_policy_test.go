@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"image-previewer/internal/domain/dto"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSizePolicy_Check(t *testing.T) {
+	t.Run("no restriction", func(t *testing.T) {
+		require.Nil(t, SizePolicy{}.Check(dto.ImageDimensions{Width: 50000, Height: 50000}))
+	})
+
+	t.Run("maximum dimensions", func(t *testing.T) {
+		p := SizePolicy{MaxWidth: 2000, MaxHeight: 1000}
+
+		require.Nil(t, p.Check(dto.ImageDimensions{Width: 2000, Height: 1000}))
+		require.Equal(t, ErrWidthTooLarge, p.Check(dto.ImageDimensions{Width: 2001, Height: 100}))
+		require.Equal(t, ErrHeightTooLarge, p.Check(dto.ImageDimensions{Width: 100, Height: 1001}))
+	})
+
+	t.Run("presets", func(t *testing.T) {
+		p := SizePolicy{
+			MaxWidth: 200,
+			Presets: []dto.ImageDimensions{
+				{Width: 100, Height: 100},
+				{Width: 300, Height: 200},
+			},
+		}
+
+		require.Nil(t, p.Check(dto.ImageDimensions{Width: 100, Height: 100}))
+		require.Equal(t, ErrSizeNotAllowed, p.Check(dto.ImageDimensions{Width: 100, Height: 200}))
+		require.Equal(t, ErrWidthTooLarge, p.Check(dto.ImageDimensions{Width: 300, Height: 200}))
+	})
+}
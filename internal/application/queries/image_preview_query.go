@@ -6,7 +6,8 @@ import (
 )
 
 type ImagePreviewQuery struct {
-	URL        string
-	Headers    domain.RequestHeaders
-	Dimensions dto.ImageDimensions
+	URL            string
+	Headers        domain.RequestHeaders
+	Transformation dto.Transformation
+	Format         dto.ImageFormat
 }
@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"image-previewer/internal/application/handlers"
+	"image-previewer/internal/domain/dto"
+	"image-previewer/internal/infrastructure/downloader"
+	"image-previewer/internal/infrastructure/processor"
+	"image/color"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/spf13/viper"
+)
+
+type config struct {
+	cacheDir         string
+	capacity         int
+	maxBytes         int64
+	background       color.NRGBA
+	filter           imaging.ResampleFilter
+	filterName       string
+	downloadTimeouts downloader.Timeouts
+	requestTimeout   time.Duration
+	maxSourceBytes   int64
+	maxSourcePixels  int
+	sizes            handlers.SizePolicy
+	signatureKey     string
+	sources          downloader.SourcePolicy
+	headers          downloader.HeaderPolicy
+	varyHeaders      []string
+	cacheMaxAge      time.Duration
+	originTTL        time.Duration
+	minFreeDisk      uint64
+	shutdownDelay    time.Duration
+}
+
+func loadConfig() (*config, error) {
+	viper.SetDefault("app.thumbnail_background", "ffffff")
+	viper.SetDefault("app.resample_filter", "lanczos")
+	viper.SetDefault("app.download_connect_timeout", 5*time.Second)
+	viper.SetDefault("app.download_read_timeout", 10*time.Second)
+	viper.SetDefault("app.download_timeout", 30*time.Second)
+	viper.SetDefault("app.request_timeout", 60*time.Second)
+	viper.SetDefault("app.max_source_bytes", 20<<20)
+	viper.SetDefault("app.max_source_pixels", 50000000)
+	viper.SetDefault("app.max_width", 4096)
+	viper.SetDefault("app.max_height", 4096)
+	viper.SetDefault("app.cache_max_age", 24*time.Hour)
+	viper.SetDefault("app.origin_default_ttl", 24*time.Hour)
+	viper.SetDefault("app.min_free_disk_bytes", 100<<20)
+	viper.SetDefault("app.shutdown_delay", 5*time.Second)
+	viper.SetDefault("app.forwarded_headers", []string{"Accept-Language", "User-Agent"})
+	viper.SetDefault("app.blocked_headers", []string{"Cookie", "Authorization"})
+
+	cfg := &config{
+		cacheDir: viper.GetString("app.preview_cache_dir"),
+		capacity: viper.GetInt("app.preview_cache_size"),
+		maxBytes: viper.GetInt64("app.preview_cache_max_bytes"),
+		downloadTimeouts: downloader.Timeouts{
+			Connect: viper.GetDuration("app.download_connect_timeout"),
+			Read:    viper.GetDuration("app.download_read_timeout"),
+			Total:   viper.GetDuration("app.download_timeout"),
+		},
+		requestTimeout:  viper.GetDuration("app.request_timeout"),
+		maxSourceBytes:  viper.GetInt64("app.max_source_bytes"),
+		maxSourcePixels: viper.GetInt("app.max_source_pixels"),
+		sizes: handlers.SizePolicy{
+			MaxWidth:  viper.GetInt("app.max_width"),
+			MaxHeight: viper.GetInt("app.max_height"),
+		},
+		signatureKey: viper.GetString("app.url_signature_key"),
+		sources: downloader.SourcePolicy{
+			AllowedHosts:         viper.GetStringSlice("app.allowed_source_hosts"),
+			DeniedHosts:          viper.GetStringSlice("app.denied_source_hosts"),
+			AllowPrivateNetworks: viper.GetBool("app.allow_private_sources"),
+		},
+		headers: downloader.HeaderPolicy{
+			Allowed: viper.GetStringSlice("app.forwarded_headers"),
+			Denied:  viper.GetStringSlice("app.blocked_headers"),
+		},
+		varyHeaders:   viper.GetStringSlice("app.cache_vary_headers"),
+		cacheMaxAge:   viper.GetDuration("app.cache_max_age"),
+		originTTL:     viper.GetDuration("app.origin_default_ttl"),
+		minFreeDisk:   viper.GetUint64("app.min_free_disk_bytes"),
+		shutdownDelay: viper.GetDuration("app.shutdown_delay"),
+	}
+
+	if cfg.capacity <= 0 && cfg.maxBytes <= 0 {
+		return nil, errors.New("invalid config: preview_cache_size or preview_cache_max_bytes should be set")
+	}
+
+	if cfg.cacheDir == "" {
+		return nil, errors.New("invalid config: preview_cache_dir should be set")
+	}
+
+	var err error
+
+	if cfg.background, err = dto.ParseHexColor(viper.GetString("app.thumbnail_background")); err != nil {
+		return nil, fmt.Errorf("invalid config: thumbnail_background: %w", err)
+	}
+
+	cfg.filterName = viper.GetString("app.resample_filter")
+
+	if cfg.filter, err = processor.FilterByName(cfg.filterName); err != nil {
+		return nil, fmt.Errorf("invalid config: resample_filter: %w", err)
+	}
+
+	if err = cfg.sources.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: source hosts: %w", err)
+	}
+
+	if err = viper.UnmarshalKey("app.origin_headers", &cfg.headers.Static); err != nil {
+		return nil, fmt.Errorf("invalid config: origin_headers: %w", err)
+	}
+
+	if cfg.sizes.Presets, err = parseSizes(viper.GetStringSlice("app.allowed_sizes")); err != nil {
+		return nil, fmt.Errorf("invalid config: allowed_sizes: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// parseSizes reads presets written as WIDTHxHEIGHT, e.g. 300x200.
+func parseSizes(values []string) ([]dto.ImageDimensions, error) {
+	sizes := make([]dto.ImageDimensions, 0, len(values))
+
+	for _, value := range values {
+		var size dto.ImageDimensions
+
+		if _, err := fmt.Sscanf(value, "%dx%d", &size.Width, &size.Height); err != nil {
+			return nil, fmt.Errorf("%q should look like 300x200", value)
+		}
+
+		if size.Width < 1 || size.Height < 1 {
+			return nil, fmt.Errorf("%q should have positive dimensions", value)
+		}
+
+		sizes = append(sizes, size)
+	}
+
+	return sizes, nil
+}
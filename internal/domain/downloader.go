@@ -1,12 +1,13 @@
 package domain
 
 import (
-	"image"
+	"context"
 	"image-previewer/internal/domain/dto"
 )
 
 type RequestHeaders map[string][]string
 
 type Downloader interface {
-	Download(url string, dimensions dto.ImageDimensions, headers RequestHeaders) (image.Image, error)
+	// Download makes a conditional request when cached carries validators.
+	Download(ctx context.Context, url string, headers RequestHeaders, cached dto.OriginCache) (*dto.SourceImage, error)
 }
@@ -0,0 +1,48 @@
+package dto
+
+import (
+	"strconv"
+	"strings"
+)
+
+type FilterType string
+
+const (
+	FilterBlur           FilterType = "blur"
+	FilterSharpen        FilterType = "sharpen"
+	FilterGrayscale      FilterType = "grayscale"
+	FilterRotate         FilterType = "rotate"
+	FilterFlipHorizontal FilterType = "flip(h)"
+	FilterFlipVertical   FilterType = "flip(v)"
+)
+
+// Filter is a single effect applied after resizing, Amount is the sigma for blur and sharpen
+// and the clockwise angle in degrees for rotate.
+type Filter struct {
+	Type   FilterType
+	Amount float64
+}
+
+type Filters []Filter
+
+func (f Filter) String() string {
+	switch f.Type {
+	case FilterBlur, FilterSharpen, FilterRotate:
+		return string(f.Type) + "(" + strconv.FormatFloat(f.Amount, 'f', -1, 64) + ")"
+	case FilterGrayscale:
+		return string(f.Type) + "()"
+	default:
+		return string(f.Type)
+	}
+}
+
+// String is the canonical form of the chain, equal chains always produce the same string.
+func (f Filters) String() string {
+	parts := make([]string, 0, len(f))
+
+	for _, filter := range f {
+		parts = append(parts, filter.String())
+	}
+
+	return strings.Join(parts, ":")
+}
@@ -0,0 +1,13 @@
+package dto
+
+type ImageFormat string
+
+const (
+	FormatJPEG ImageFormat = "jpeg"
+	FormatPNG  ImageFormat = "png"
+	FormatGIF  ImageFormat = "gif"
+	FormatWebP ImageFormat = "webp"
+	FormatBMP  ImageFormat = "bmp"
+	// FormatAuto is decided once the image is rendered: png if it has transparency, jpeg otherwise.
+	FormatAuto ImageFormat = "auto"
+)
@@ -0,0 +1,21 @@
+package dto
+
+import "time"
+
+// OriginCache is what the origin told about caching its image: validators for conditional
+// requests and the moment the copy stops being fresh.
+type OriginCache struct {
+	ETag         string
+	LastModified string
+	Expires      time.Time
+}
+
+// Stale reports whether the origin has to be asked again, a zero Expires never goes stale.
+func (c OriginCache) Stale(now time.Time) bool {
+	return !c.Expires.IsZero() && !now.Before(c.Expires)
+}
+
+// Validators reports whether a conditional request can be made at all.
+func (c OriginCache) Validators() bool {
+	return c.ETag != "" || c.LastModified != ""
+}
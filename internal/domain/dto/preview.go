@@ -0,0 +1,40 @@
+package dto
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// Preview is an encoded preview ready to be sent to the client.
+type Preview struct {
+	Content     []byte
+	ContentType string
+	// ETag and ModTime are validators for conditional requests, set once the preview is rendered.
+	ETag    string
+	ModTime time.Time
+	Origin  OriginCache
+}
+
+// Stream gives every caller its own reader over the shared content.
+func (p *Preview) Stream() *PreviewStream {
+	return &PreviewStream{
+		Content:     ioutil.NopCloser(bytes.NewReader(p.Content)),
+		ContentType: p.ContentType,
+		Size:        int64(len(p.Content)),
+		ETag:        p.ETag,
+		ModTime:     p.ModTime,
+		Origin:      p.Origin,
+	}
+}
+
+// PreviewStream is an encoded preview copied to the client as is, closing Content is on the reader.
+type PreviewStream struct {
+	Content     io.ReadCloser
+	ContentType string
+	Size        int64
+	ETag        string
+	ModTime     time.Time
+	Origin      OriginCache
+}
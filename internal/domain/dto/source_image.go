@@ -0,0 +1,10 @@
+package dto
+
+// SourceImage is the original image as fetched from the origin, before any processing.
+type SourceImage struct {
+	Content     []byte
+	ContentType string
+	Origin      OriginCache
+	// NotModified is set when the origin confirmed a cached copy, Content is empty then.
+	NotModified bool
+}
@@ -0,0 +1,121 @@
+package dto
+
+import (
+	"errors"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+type ResizeMode string
+
+const (
+	// ModeFill scales and crops the image to cover the whole box.
+	ModeFill ResizeMode = "fill"
+	// ModeFit scales the image down to fit into the box, preserving aspect ratio.
+	ModeFit ResizeMode = "fit"
+	// ModeResize stretches the image to the exact box size.
+	ModeResize ResizeMode = "resize"
+	// ModeCrop cuts the box out of the image without scaling.
+	ModeCrop ResizeMode = "crop"
+	// ModeThumbnail fits the image into the box and letterboxes the rest with the background colour.
+	ModeThumbnail ResizeMode = "thumbnail"
+)
+
+var ResizeModes = []ResizeMode{ModeFill, ModeFit, ModeResize, ModeCrop, ModeThumbnail}
+
+type Gravity string
+
+const (
+	GravityCenter    Gravity = "center"
+	GravityNorth     Gravity = "north"
+	GravitySouth     Gravity = "south"
+	GravityEast      Gravity = "east"
+	GravityWest      Gravity = "west"
+	GravityNorthEast Gravity = "north-east"
+	GravityNorthWest Gravity = "north-west"
+	GravitySouthEast Gravity = "south-east"
+	GravitySouthWest Gravity = "south-west"
+	// GravitySmart picks the crop window with the most detail.
+	GravitySmart Gravity = "smart"
+)
+
+var Gravities = []Gravity{
+	GravityCenter,
+	GravityNorth,
+	GravitySouth,
+	GravityEast,
+	GravityWest,
+	GravityNorthEast,
+	GravityNorthWest,
+	GravitySouthEast,
+	GravitySouthWest,
+	GravitySmart,
+}
+
+var ErrInvalidColor = errors.New("color should be in RGB, RRGGBB or RRGGBBAA hex form")
+
+type Transformation struct {
+	Mode       ResizeMode
+	Dimensions ImageDimensions
+	// Gravity anchors the crop window of fill and crop modes, empty means center.
+	Gravity    Gravity
+	Background color.NRGBA
+	// Filters are applied in order on the resized image.
+	Filters Filters
+	// IgnoreOrientation keeps jpeg pixels as stored instead of turning them upright by EXIF orientation.
+	IgnoreOrientation bool
+}
+
+func (m ResizeMode) IsValid() bool {
+	for _, mode := range ResizeModes {
+		if m == mode {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Crops reports whether the mode cuts part of the image away, which is when gravity matters.
+func (m ResizeMode) Crops() bool {
+	return m == ModeFill || m == ModeCrop
+}
+
+func (g Gravity) IsValid() bool {
+	for _, gravity := range Gravities {
+		if g == gravity {
+			return true
+		}
+	}
+
+	return false
+}
+
+func ParseHexColor(value string) (color.NRGBA, error) {
+	value = strings.TrimPrefix(value, "#")
+
+	if len(value) == 3 {
+		value = string([]byte{value[0], value[0], value[1], value[1], value[2], value[2]})
+	}
+
+	if len(value) == 6 {
+		value += "ff"
+	}
+
+	if len(value) != 8 {
+		return color.NRGBA{}, ErrInvalidColor
+	}
+
+	rgba, err := strconv.ParseUint(value, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, ErrInvalidColor
+	}
+
+	return color.NRGBA{
+		R: uint8(rgba >> 24),
+		G: uint8(rgba >> 16),
+		B: uint8(rgba >> 8),
+		A: uint8(rgba),
+	}, nil
+}
@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Error kinds, every error surfaced to the client should wrap exactly one of them.
+var (
+	ErrValidation       = errors.New("invalid request")
+	ErrUpstreamNotFound = errors.New("upstream resource not found")
+	ErrUpstreamFailure  = errors.New("upstream resource failure")
+	ErrUpstreamTimeout  = errors.New("upstream resource timeout")
+	ErrUnsupportedMedia = errors.New("unsupported media")
+	ErrPayloadTooLarge  = errors.New("payload too large")
+	ErrUnprocessable    = errors.New("unprocessable entity")
+	ErrForbidden        = errors.New("forbidden")
+)
+
+type kindError struct {
+	kind    error
+	message string
+}
+
+func (e *kindError) Error() string {
+	return e.message
+}
+
+func (e *kindError) Unwrap() error {
+	return e.kind
+}
+
+// NewError creates an error that keeps its own message but matches kind with errors.Is.
+func NewError(kind error, message string) error {
+	return &kindError{
+		kind:    kind,
+		message: message,
+	}
+}
+
+// UpstreamError describes an unsuccessful origin response, Err holds the classified cause.
+type UpstreamError struct {
+	URL        string
+	StatusCode int
+	// Header keeps only the origin headers worth relaying to the client.
+	Header http.Header
+	Err    error
+}
+
+func (e *UpstreamError) Error() string {
+	return fmt.Sprintf("%s: %s responded with %d %s", e.Err, e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+func (e *UpstreamError) Unwrap() error {
+	return e.Err
+}
@@ -5,5 +5,5 @@ import "image-previewer/internal/domain/dto"
 type ImageID string
 
 type ImageIDResolver interface {
-	ResolveImageID(url string, dim dto.ImageDimensions) ImageID
+	ResolveImageID(url string, headers RequestHeaders, t dto.Transformation, format dto.ImageFormat) ImageID
 }
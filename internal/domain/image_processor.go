@@ -0,0 +1,13 @@
+package domain
+
+import (
+	"image"
+	"image-previewer/internal/domain/dto"
+)
+
+// ImageProcessor is a decode -> transform -> encode pipeline, each stage can be used on its own.
+type ImageProcessor interface {
+	Decode(src *dto.SourceImage, autoOrient bool) (image.Image, error)
+	Transform(img image.Image, t dto.Transformation) (image.Image, error)
+	Encode(img image.Image, format dto.ImageFormat) (*dto.Preview, error)
+}
@@ -1,8 +1,14 @@
 package domain
 
-import "image"
+import (
+	"context"
+	"image-previewer/internal/domain/dto"
+)
 
 type PreviewRepository interface {
-	FindOne(id ImageID) (image.Image, error)
-	Add(id ImageID, img image.Image) (bool, error)
+	FindOne(ctx context.Context, id ImageID) (*dto.PreviewStream, error)
+	// Add stores the preview, replacing a previous one with the same id.
+	Add(ctx context.Context, id ImageID, preview *dto.Preview) (bool, error)
+	// Refresh keeps the stored preview but replaces what is known about its origin.
+	Refresh(ctx context.Context, id ImageID, origin dto.OriginCache) (*dto.Preview, error)
 }
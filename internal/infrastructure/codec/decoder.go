@@ -0,0 +1,151 @@
+package codec
+
+import (
+	"image"
+	"image-previewer/internal/domain/dto"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/webp"
+)
+
+type Decoder interface {
+	Format() dto.ImageFormat
+	// Match reports whether the leading bytes of a source look like this format.
+	Match(head []byte) bool
+	// Accepts reports whether the mime type announced by the origin belongs to this format.
+	Accepts(contentType string) bool
+	// ContentTypes are the mime types of the format, used to tell origins what we can read.
+	ContentTypes() []string
+	Decode(r io.Reader) (image.Image, error)
+	// DecodeConfig reads only the header, it is cheap enough to run before the full decode.
+	DecodeConfig(r io.Reader) (image.Config, error)
+}
+
+// SignatureDecoder recognizes a format by its magic bytes, "?" in a signature matches any byte.
+type SignatureDecoder struct {
+	format       dto.ImageFormat
+	signatures   []string
+	contentTypes []string
+	decode       func(io.Reader) (image.Image, error)
+	decodeConfig func(io.Reader) (image.Config, error)
+}
+
+func (d *SignatureDecoder) Format() dto.ImageFormat {
+	return d.format
+}
+
+func (d *SignatureDecoder) Match(head []byte) bool {
+	for _, signature := range d.signatures {
+		if matchSignature(head, signature) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d *SignatureDecoder) Accepts(contentType string) bool {
+	for _, t := range d.contentTypes {
+		if t == contentType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d *SignatureDecoder) ContentTypes() []string {
+	return d.contentTypes
+}
+
+func (d *SignatureDecoder) Decode(r io.Reader) (image.Image, error) {
+	return d.decode(r)
+}
+
+func (d *SignatureDecoder) DecodeConfig(r io.Reader) (image.Config, error) {
+	return d.decodeConfig(r)
+}
+
+func matchSignature(head []byte, signature string) bool {
+	if len(head) < len(signature) {
+		return false
+	}
+
+	for i := 0; i < len(signature); i++ {
+		if signature[i] != '?' && signature[i] != head[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func NewSignatureDecoder(
+	format dto.ImageFormat,
+	signatures []string,
+	contentTypes []string,
+	decode func(io.Reader) (image.Image, error),
+	decodeConfig func(io.Reader) (image.Config, error),
+) *SignatureDecoder {
+	return &SignatureDecoder{
+		format:       format,
+		signatures:   signatures,
+		contentTypes: contentTypes,
+		decode:       decode,
+		decodeConfig: decodeConfig,
+	}
+}
+
+func NewJPEGDecoder() *SignatureDecoder {
+	return NewSignatureDecoder(
+		dto.FormatJPEG,
+		[]string{"\xff\xd8"},
+		[]string{"image/jpeg", "image/jpg", "image/pjpeg"},
+		jpeg.Decode,
+		jpeg.DecodeConfig,
+	)
+}
+
+func NewPNGDecoder() *SignatureDecoder {
+	return NewSignatureDecoder(
+		dto.FormatPNG,
+		[]string{"\x89PNG\r\n\x1a\n"},
+		[]string{"image/png", "image/apng"},
+		png.Decode,
+		png.DecodeConfig,
+	)
+}
+
+func NewGIFDecoder() *SignatureDecoder {
+	return NewSignatureDecoder(
+		dto.FormatGIF,
+		[]string{"GIF87a", "GIF89a"},
+		[]string{"image/gif"},
+		gif.Decode,
+		gif.DecodeConfig,
+	)
+}
+
+func NewWebPDecoder() *SignatureDecoder {
+	return NewSignatureDecoder(
+		dto.FormatWebP,
+		[]string{"RIFF????WEBPVP8"},
+		[]string{"image/webp"},
+		webp.Decode,
+		webp.DecodeConfig,
+	)
+}
+
+func NewBMPDecoder() *SignatureDecoder {
+	return NewSignatureDecoder(
+		dto.FormatBMP,
+		[]string{"BM"},
+		[]string{"image/bmp", "image/x-bmp", "image/x-ms-bmp"},
+		bmp.Decode,
+		bmp.DecodeConfig,
+	)
+}
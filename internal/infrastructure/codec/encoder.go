@@ -0,0 +1,70 @@
+package codec
+
+import (
+	"image"
+	"image-previewer/internal/domain/dto"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"golang.org/x/image/bmp"
+)
+
+type Encoder interface {
+	Format() dto.ImageFormat
+	ContentType() string
+	Encode(w io.Writer, img image.Image) error
+}
+
+type FuncEncoder struct {
+	format      dto.ImageFormat
+	contentType string
+	encode      func(io.Writer, image.Image) error
+}
+
+func (e *FuncEncoder) Format() dto.ImageFormat {
+	return e.format
+}
+
+func (e *FuncEncoder) ContentType() string {
+	return e.contentType
+}
+
+func (e *FuncEncoder) Encode(w io.Writer, img image.Image) error {
+	return e.encode(w, img)
+}
+
+func NewFuncEncoder(
+	format dto.ImageFormat,
+	contentType string,
+	encode func(io.Writer, image.Image) error,
+) *FuncEncoder {
+	return &FuncEncoder{
+		format:      format,
+		contentType: contentType,
+		encode:      encode,
+	}
+}
+
+func NewJPEGEncoder(quality int) *FuncEncoder {
+	return NewFuncEncoder(dto.FormatJPEG, "image/jpeg", func(w io.Writer, img image.Image) error {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	})
+}
+
+func NewPNGEncoder() *FuncEncoder {
+	encoder := &png.Encoder{CompressionLevel: png.BestSpeed}
+
+	return NewFuncEncoder(dto.FormatPNG, "image/png", encoder.Encode)
+}
+
+func NewGIFEncoder() *FuncEncoder {
+	return NewFuncEncoder(dto.FormatGIF, "image/gif", func(w io.Writer, img image.Image) error {
+		return gif.Encode(w, img, nil)
+	})
+}
+
+func NewBMPEncoder() *FuncEncoder {
+	return NewFuncEncoder(dto.FormatBMP, "image/bmp", bmp.Encode)
+}
@@ -0,0 +1,130 @@
+package codec
+
+import (
+	"encoding/binary"
+	"image"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	OrientationNormal = 1
+	orientationTag    = 0x0112
+	markerSOS         = 0xda
+	markerAPP1        = 0xe1
+)
+
+// JPEGOrientation reads the EXIF orientation (1-8) from the APP1 segment of a jpeg,
+// any missing or malformed metadata is reported as OrientationNormal.
+func JPEGOrientation(data []byte) int {
+	if len(data) < 2 || data[0] != 0xff || data[1] != 0xd8 {
+		return OrientationNormal
+	}
+
+	for pos := 2; pos+4 <= len(data); {
+		if data[pos] != 0xff {
+			return OrientationNormal
+		}
+
+		marker := data[pos+1]
+		if marker == 0xff {
+			pos++
+
+			continue
+		}
+
+		if marker == markerSOS {
+			return OrientationNormal
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+
+		if length < 2 || end > len(data) {
+			return OrientationNormal
+		}
+
+		if marker == markerAPP1 {
+			if orientation, ok := exifOrientation(data[pos+4 : end]); ok {
+				return orientation
+			}
+		}
+
+		pos = end
+	}
+
+	return OrientationNormal
+}
+
+func exifOrientation(segment []byte) (int, bool) {
+	const header = "Exif\x00\x00"
+
+	if len(segment) < len(header)+8 || string(segment[:len(header)]) != header {
+		return 0, false
+	}
+
+	tiff := segment[len(header):]
+
+	var order binary.ByteOrder
+
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0, false
+	}
+
+	// bounds are checked before converting, a large offset would wrap around a 32-bit int
+	offset := order.Uint32(tiff[4:])
+	if uint64(offset)+2 > uint64(len(tiff)) {
+		return 0, false
+	}
+
+	ifd := int(offset)
+
+	entries := int(order.Uint16(tiff[ifd:]))
+
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0, false
+		}
+
+		if order.Uint16(tiff[entry:]) != orientationTag {
+			continue
+		}
+
+		orientation := int(order.Uint16(tiff[entry+8:]))
+		if orientation < 1 || orientation > 8 {
+			return 0, false
+		}
+
+		return orientation, true
+	}
+
+	return 0, false
+}
+
+// Orient turns an image stored with the given EXIF orientation upright.
+func Orient(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		return imaging.FlipH(img)
+	case 3:
+		return imaging.Rotate180(img)
+	case 4:
+		return imaging.FlipV(img)
+	case 5:
+		return imaging.Transpose(img)
+	case 6:
+		return imaging.Rotate270(img)
+	case 7:
+		return imaging.Transverse(img)
+	case 8:
+		return imaging.Rotate90(img)
+	default:
+		return img
+	}
+}
@@ -0,0 +1,61 @@
+package codec
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJPEGOrientation(t *testing.T) {
+	for orientation := 1; orientation <= 8; orientation++ {
+		data, err := ioutil.ReadFile(fmt.Sprintf("../../../tests/data/orientation/orientation_%d.jpg", orientation))
+		require.Nil(t, err)
+
+		require.Equal(t, orientation, JPEGOrientation(data))
+	}
+
+	t.Run("no exif", func(t *testing.T) {
+		data, _ := ioutil.ReadFile("../../../tests/data/_gopher_500x500.jpg")
+
+		require.Equal(t, OrientationNormal, JPEGOrientation(data))
+	})
+
+	t.Run("malformed data", func(t *testing.T) {
+		for _, data := range [][]byte{
+			nil,
+			[]byte("\x89PNG"),
+			[]byte("\xff\xd8\xff\xe1\xff\xff"),
+			[]byte("\xff\xd8\xff\xe1\x00\x10Exif\x00\x00MM\x00\x2a\xff\xff\xff\xff"),
+			[]byte("\xff\xd8\xff\xe1\x00\x10Exif\x00\x00II\x2a\x00\xfe\xff\xff\x7f"),
+		} {
+			require.Equal(t, OrientationNormal, JPEGOrientation(data))
+		}
+	})
+}
+
+func TestOrient(t *testing.T) {
+	for orientation := 1; orientation <= 8; orientation++ {
+		data, _ := ioutil.ReadFile(fmt.Sprintf("../../../tests/data/orientation/orientation_%d.jpg", orientation))
+
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		require.Nil(t, err)
+
+		upright := Orient(img, JPEGOrientation(data))
+
+		require.Equal(t, image.Rect(0, 0, 64, 32), upright.Bounds(), orientation)
+		require.True(t, isRed(upright, 4, 4), orientation)
+		require.False(t, isRed(upright, 60, 4), orientation)
+		require.False(t, isRed(upright, 4, 28), orientation)
+	}
+}
+
+func isRed(img image.Image, x, y int) bool {
+	r, g, b, _ := img.At(x, y).RGBA()
+
+	return r > 0xc000 && g < 0x4000 && b < 0x4000
+}
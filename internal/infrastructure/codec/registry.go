@@ -0,0 +1,153 @@
+package codec
+
+import (
+	"bufio"
+	"errors"
+	"image"
+	"image-previewer/internal/domain/dto"
+	"image/jpeg"
+	"io"
+	"mime"
+	"strings"
+)
+
+// sniffLen covers the longest built-in signature (RIFF????WEBPVP8).
+const sniffLen = 16
+
+var ErrUnsupportedFormat = errors.New("image format is not supported")
+
+type Registry struct {
+	decoders []Decoder
+	encoders []Encoder
+}
+
+func (r *Registry) RegisterDecoder(d Decoder) {
+	r.decoders = append(r.decoders, d)
+}
+
+func (r *Registry) RegisterEncoder(e Encoder) {
+	r.encoders = append(r.encoders, e)
+}
+
+// Detect picks a decoder by magic bytes first and falls back to the announced content type,
+// origins are often wrong about the latter.
+func (r *Registry) Detect(head []byte, contentType string) (Decoder, error) {
+	for _, d := range r.decoders {
+		if d.Match(head) {
+			return d, nil
+		}
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		mediaType = strings.ToLower(mediaType)
+
+		for _, d := range r.decoders {
+			if d.Accepts(mediaType) {
+				return d, nil
+			}
+		}
+	}
+
+	return nil, ErrUnsupportedFormat
+}
+
+func (r *Registry) Decode(src io.Reader, contentType string) (image.Image, dto.ImageFormat, error) {
+	d, buf, err := r.sniff(src, contentType)
+	if err != nil {
+		return nil, "", err
+	}
+
+	img, err := d.Decode(buf)
+	if err != nil {
+		return nil, d.Format(), err
+	}
+
+	return img, d.Format(), nil
+}
+
+func (r *Registry) DecodeConfig(src io.Reader, contentType string) (image.Config, dto.ImageFormat, error) {
+	d, buf, err := r.sniff(src, contentType)
+	if err != nil {
+		return image.Config{}, "", err
+	}
+
+	cfg, err := d.DecodeConfig(buf)
+	if err != nil {
+		return image.Config{}, d.Format(), err
+	}
+
+	return cfg, d.Format(), nil
+}
+
+func (r *Registry) sniff(src io.Reader, contentType string) (Decoder, *bufio.Reader, error) {
+	buf := bufio.NewReader(src)
+
+	head, err := buf.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+
+	d, err := r.Detect(head, contentType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return d, buf, nil
+}
+
+func (r *Registry) Encoder(format dto.ImageFormat) (Encoder, error) {
+	for _, e := range r.encoders {
+		if e.Format() == format {
+			return e, nil
+		}
+	}
+
+	return nil, ErrUnsupportedFormat
+}
+
+func (r *Registry) EncoderByContentType(contentType string) (Encoder, error) {
+	for _, e := range r.encoders {
+		if e.ContentType() == contentType {
+			return e, nil
+		}
+	}
+
+	return nil, ErrUnsupportedFormat
+}
+
+// Accept lists the content types of every registered decoder, it is sent to origins instead of
+// the client's Accept so they never pick a format we can't read.
+func (r *Registry) Accept() string {
+	types := make([]string, 0, len(r.decoders))
+
+	for _, d := range r.decoders {
+		types = append(types, d.ContentTypes()...)
+	}
+
+	return strings.Join(types, ", ")
+}
+
+func NewRegistry(decoders ...Decoder) *Registry {
+	return &Registry{
+		decoders: decoders,
+	}
+}
+
+// NewDefaultRegistry knows every format the standard library and x/image can read,
+// but there is no pure Go webp encoder, so webp can only be a source format.
+func NewDefaultRegistry() *Registry {
+	r := NewRegistry(
+		NewJPEGDecoder(),
+		NewPNGDecoder(),
+		NewGIFDecoder(),
+		NewWebPDecoder(),
+		NewBMPDecoder(),
+	)
+
+	r.RegisterEncoder(NewJPEGEncoder(jpeg.DefaultQuality))
+	r.RegisterEncoder(NewPNGEncoder())
+	r.RegisterEncoder(NewGIFEncoder())
+	r.RegisterEncoder(NewBMPEncoder())
+
+	return r
+}
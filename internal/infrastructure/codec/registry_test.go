@@ -0,0 +1,130 @@
+package codec
+
+import (
+	"bytes"
+	"image"
+	"image-previewer/internal/domain/dto"
+	"image/gif"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/bmp"
+)
+
+func TestRegistry_Detect(t *testing.T) {
+	r := NewDefaultRegistry()
+
+	t.Run("detect by magic bytes", func(t *testing.T) {
+		cases := map[dto.ImageFormat][]byte{
+			dto.FormatJPEG: []byte("\xff\xd8\xff\xe0\x00\x10JFIF"),
+			dto.FormatPNG:  []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"),
+			dto.FormatGIF:  []byte("GIF89a\x01\x00\x01\x00"),
+			dto.FormatWebP: []byte("RIFF\x24\x00\x00\x00WEBPVP8 "),
+			dto.FormatBMP:  []byte("BM\x36\x00\x00\x00\x00\x00"),
+		}
+
+		for format, head := range cases {
+			d, err := r.Detect(head, "application/octet-stream")
+
+			require.Nil(t, err)
+			require.Equal(t, format, d.Format())
+		}
+	})
+
+	t.Run("magic bytes win over content type", func(t *testing.T) {
+		d, err := r.Detect([]byte("GIF89a"), "image/jpeg")
+
+		require.Nil(t, err)
+		require.Equal(t, dto.FormatGIF, d.Format())
+	})
+
+	t.Run("fallback to content type", func(t *testing.T) {
+		d, err := r.Detect([]byte("????"), "image/PNG; charset=binary")
+
+		require.Nil(t, err)
+		require.Equal(t, dto.FormatPNG, d.Format())
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		d, err := r.Detect([]byte("<html>"), "text/html")
+
+		require.Nil(t, d)
+		require.Equal(t, ErrUnsupportedFormat, err)
+	})
+}
+
+func TestRegistry_Decode(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 8, 4))
+
+	gifBuf := new(bytes.Buffer)
+	require.Nil(t, gif.Encode(gifBuf, src, nil))
+
+	bmpBuf := new(bytes.Buffer)
+	require.Nil(t, bmp.Encode(bmpBuf, src))
+
+	jpegData, _ := ioutil.ReadFile("../../../tests/data/_gopher_500x500.jpg")
+	pngData, _ := ioutil.ReadFile("../../../tests/data/_gopher_original_1024x504.png")
+
+	cases := []struct {
+		format dto.ImageFormat
+		data   []byte
+		width  int
+	}{
+		{dto.FormatJPEG, jpegData, 500},
+		{dto.FormatPNG, pngData, 1024},
+		{dto.FormatGIF, gifBuf.Bytes(), 8},
+		{dto.FormatBMP, bmpBuf.Bytes(), 8},
+	}
+
+	for _, c := range cases {
+		img, format, err := NewDefaultRegistry().Decode(bytes.NewReader(c.data), "")
+
+		require.Nil(t, err)
+		require.Equal(t, c.format, format)
+		require.Equal(t, c.width, img.Bounds().Dx())
+	}
+
+	t.Run("custom decoder", func(t *testing.T) {
+		r := NewRegistry()
+		r.RegisterDecoder(NewSignatureDecoder(
+			dto.ImageFormat("fake"),
+			[]string{"FAKE"},
+			nil,
+			func(_ io.Reader) (image.Image, error) {
+				return src, nil
+			},
+			func(_ io.Reader) (image.Config, error) {
+				return image.Config{Width: 8, Height: 4}, nil
+			},
+		))
+
+		img, format, err := r.Decode(bytes.NewBufferString("FAKE"), "")
+
+		require.Nil(t, err)
+		require.Equal(t, dto.ImageFormat("fake"), format)
+		require.Same(t, src, img)
+
+		cfg, _, err := r.DecodeConfig(bytes.NewBufferString("FAKE"), "")
+
+		require.Nil(t, err)
+		require.Equal(t, 8, cfg.Width)
+	})
+}
+
+func TestRegistry_DecodeConfig(t *testing.T) {
+	data, _ := ioutil.ReadFile("../../../tests/data/_gopher_original_1024x504.png")
+
+	cfg, format, err := NewDefaultRegistry().DecodeConfig(bytes.NewReader(data), "")
+
+	require.Nil(t, err)
+	require.Equal(t, dto.FormatPNG, format)
+	require.Equal(t, 1024, cfg.Width)
+	require.Equal(t, 504, cfg.Height)
+}
+
+func TestRegistry_Accept(t *testing.T) {
+	require.Equal(t, "image/png, image/apng, image/gif", NewRegistry(NewPNGDecoder(), NewGIFDecoder()).Accept())
+	require.NotContains(t, NewDefaultRegistry().Accept(), "avif")
+}
@@ -0,0 +1,92 @@
+package downloader
+
+import (
+	"image-previewer/internal/domain"
+	"net/http"
+	"strings"
+)
+
+// strippedHeaders never reach the origin whatever the policy says: hop-by-hop headers belong to
+// the client connection, the transport manages encoding itself, and conditional or range requests
+// would get answers other than a full 200 body.
+var strippedHeaders = canonicalSet([]string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization", "Proxy-Connection",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade", "Host", "Content-Length", "Accept-Encoding",
+	"Range", "If-Range", "If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since",
+})
+
+// OriginHeaders are injected into requests to matching hosts, an empty host list matches any origin.
+type OriginHeaders struct {
+	Hosts   []string
+	Headers map[string]string
+}
+
+// HeaderPolicy decides which client headers are forwarded to the origin. An empty allow list
+// forwards everything not denied, static headers override forwarded ones.
+type HeaderPolicy struct {
+	Allowed []string
+	Denied  []string
+	Static  []OriginHeaders
+	// Accept replaces the client's Accept, which speaks for browsers rather than for our decoders
+	Accept string
+}
+
+func (p HeaderPolicy) Apply(host string, headers domain.RequestHeaders) http.Header {
+	allowed := canonicalSet(p.Allowed)
+	denied := canonicalSet(p.Denied)
+	listed := connectionHeaders(headers)
+	forwarded := make(http.Header)
+
+	for name, values := range headers {
+		name = http.CanonicalHeaderKey(name)
+
+		if strippedHeaders[name] || listed[name] || denied[name] || (len(allowed) > 0 && !allowed[name]) {
+			continue
+		}
+
+		forwarded[name] = append([]string(nil), values...)
+	}
+
+	if p.Accept != "" {
+		forwarded.Set("Accept", p.Accept)
+	}
+
+	host = strings.ToLower(host)
+
+	for _, static := range p.Static {
+		if len(static.Hosts) > 0 && !matchHost(static.Hosts, host) {
+			continue
+		}
+
+		for name, value := range static.Headers {
+			forwarded.Set(name, value)
+		}
+	}
+
+	return forwarded
+}
+
+// connectionHeaders are the ones the client marked as hop-by-hop in its Connection header.
+func connectionHeaders(headers domain.RequestHeaders) map[string]bool {
+	var names []string
+
+	for _, value := range http.Header(headers).Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	return canonicalSet(names)
+}
+
+func canonicalSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+
+	for _, name := range names {
+		set[http.CanonicalHeaderKey(name)] = true
+	}
+
+	return set
+}
@@ -0,0 +1,62 @@
+package downloader
+
+import (
+	"image-previewer/internal/domain"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaderPolicy_Apply(t *testing.T) {
+	client := domain.RequestHeaders{
+		"Accept":          {"image/webp,image/*"},
+		"Accept-Encoding": {"gzip, br"},
+		"Cookie":          {"session=secret"},
+		"Connection":      {"keep-alive, X-Debug"},
+		"X-Debug":         {"1"},
+		"If-None-Match":   {`"abc"`},
+		"User-Agent":      {"Mozilla/5.0"},
+	}
+
+	t.Run("everything but denied and hop-by-hop", func(t *testing.T) {
+		headers := HeaderPolicy{Denied: []string{"cookie"}}.Apply("example.com", client)
+
+		require.Equal(t, http.Header{
+			"Accept":     {"image/webp,image/*"},
+			"User-Agent": {"Mozilla/5.0"},
+		}, headers)
+	})
+
+	t.Run("allow list", func(t *testing.T) {
+		headers := HeaderPolicy{Allowed: []string{"accept", "cookie"}, Denied: []string{"Cookie"}}.Apply("example.com", client)
+
+		require.Equal(t, http.Header{"Accept": {"image/webp,image/*"}}, headers)
+	})
+
+	t.Run("own accept", func(t *testing.T) {
+		headers := HeaderPolicy{Allowed: []string{"Accept", "User-Agent"}, Accept: "image/jpeg, image/png"}.Apply("example.com", client)
+
+		require.Equal(t, http.Header{
+			"Accept":     {"image/jpeg, image/png"},
+			"User-Agent": {"Mozilla/5.0"},
+		}, headers)
+	})
+
+	t.Run("static headers per origin", func(t *testing.T) {
+		policy := HeaderPolicy{
+			Allowed: []string{"User-Agent"},
+			Static: []OriginHeaders{
+				{Headers: map[string]string{"user-agent": "image-previewer"}},
+				{Hosts: []string{"*.example.com"}, Headers: map[string]string{"Authorization": "Bearer token"}},
+			},
+		}
+
+		require.Equal(t, http.Header{
+			"User-Agent":    {"image-previewer"},
+			"Authorization": {"Bearer token"},
+		}, policy.Apply("Images.Example.com", client))
+
+		require.Equal(t, http.Header{"User-Agent": {"image-previewer"}}, policy.Apply("example.org", client))
+	})
+}
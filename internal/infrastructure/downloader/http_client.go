@@ -1,20 +1,45 @@
 package downloader
 
 import (
+	"context"
+	"fmt"
 	"image-previewer/internal/domain"
+	"net"
 	"net/http"
 	"net/url"
+	"time"
 )
 
 type Client interface {
-	Get(rawURL string, headers domain.RequestHeaders) (resp *http.Response, err error)
+	// Get sends the client headers through the header policy, validators are our own and sent as is.
+	Get(ctx context.Context, rawURL string, headers domain.RequestHeaders, validators http.Header) (*http.Response, error)
 }
 
+// Timeouts of origin requests, zero disables the corresponding limit.
+type Timeouts struct {
+	// Connect bounds dialing and the TLS handshake.
+	Connect time.Duration
+	// Read bounds waiting for response headers once the request is sent.
+	Read time.Duration
+	// Total bounds the whole request including reading the body.
+	Total time.Duration
+}
+
+// maxRedirects matches the standard library default, which is lost once CheckRedirect is set.
+const maxRedirects = 10
+
 type HTTPClient struct {
-	client *http.Client
+	client  *http.Client
+	policy  SourcePolicy
+	headers HeaderPolicy
 }
 
-func (c *HTTPClient) Get(rawURL string, headers domain.RequestHeaders) (resp *http.Response, err error) {
+func (c *HTTPClient) Get(
+	ctx context.Context,
+	rawURL string,
+	headers domain.RequestHeaders,
+	validators http.Header,
+) (*http.Response, error) {
 	uri, err := url.Parse(rawURL)
 	if err != nil {
 		return nil, err
@@ -24,19 +49,60 @@ func (c *HTTPClient) Get(rawURL string, headers domain.RequestHeaders) (resp *ht
 		uri.Scheme = "http"
 	}
 
-	//nolint:noctx
-	req, err := http.NewRequest(http.MethodGet, uri.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri.String(), nil)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header = http.Header(headers)
+	// the reparsed url, a schemeless source only gets its host once the scheme is in place
+	if err := c.policy.CheckURL(req.URL); err != nil {
+		return nil, err
+	}
+
+	req.Header = c.headers.Apply(req.URL.Hostname(), headers)
+
+	for name, values := range validators {
+		req.Header[name] = values
+	}
 
 	return c.client.Do(req)
 }
 
-func NewHTTPClient(client *http.Client) *HTTPClient {
+func NewHTTPClient(client *http.Client, policy SourcePolicy, headers HeaderPolicy) *HTTPClient {
 	return &HTTPClient{
-		client: client,
+		client:  client,
+		policy:  policy,
+		headers: headers,
+	}
+}
+
+// NewTimeoutClient builds a standard library client that never waits on an origin forever
+// and applies the source policy to redirects and to every resolved address it dials.
+func NewTimeoutClient(timeouts Timeouts, policy SourcePolicy) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   timeouts.Connect,
+		KeepAlive: 30 * time.Second,
+		Control:   policy.control,
+	}
+
+	return &http.Client{
+		Timeout: timeouts.Total,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+
+			return policy.CheckURL(req.URL)
+		},
+		Transport: &http.Transport{
+			// no proxy: the dial guard has to see the origin's address, not the proxy's
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   timeouts.Connect,
+			ResponseHeaderTimeout: timeouts.Read,
+			IdleConnTimeout:       90 * time.Second,
+			MaxIdleConns:          100,
+			ExpectContinueTimeout: time.Second,
+		},
 	}
 }
@@ -2,10 +2,16 @@ package downloader
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"image-previewer/internal/domain"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -36,12 +42,12 @@ func TestHTTPClient_Get(t *testing.T) {
 			}
 		})
 
-		httpClient := NewHTTPClient(client)
+		httpClient := NewHTTPClient(client, SourcePolicy{}, HeaderPolicy{})
 
 		headers := make(domain.RequestHeaders)
 		headers["Accept"] = []string{"application/json"}
 
-		resp, err := httpClient.Get("yandex.ru/image.png", headers)
+		resp, err := httpClient.Get(context.Background(), "yandex.ru/image.png", headers, nil)
 		require.Nil(t, err)
 
 		defer resp.Body.Close()
@@ -49,3 +55,151 @@ func TestHTTPClient_Get(t *testing.T) {
 		require.Equal(t, 200, resp.StatusCode)
 	})
 }
+
+func TestHTTPClient_Timeouts(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+
+	defer server.Close()
+	defer close(release)
+
+	local := SourcePolicy{AllowPrivateNetworks: true}
+
+	t.Run("cancelled context stops request", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		resp, err := NewHTTPClient(NewTimeoutClient(Timeouts{}, local), local, HeaderPolicy{}).Get(ctx, server.URL, nil, nil)
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		require.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+
+	t.Run("read timeout", func(t *testing.T) {
+		client := NewTimeoutClient(Timeouts{Read: 50 * time.Millisecond}, local)
+
+		resp, err := NewHTTPClient(client, local, HeaderPolicy{}).Get(context.Background(), server.URL, nil, nil)
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		var netErr net.Error
+
+		require.True(t, errors.As(err, &netErr))
+		require.True(t, netErr.Timeout())
+	})
+
+	t.Run("total timeout", func(t *testing.T) {
+		client := NewTimeoutClient(Timeouts{Total: 50 * time.Millisecond}, local)
+
+		resp, err := NewHTTPClient(client, local, HeaderPolicy{}).Get(context.Background(), server.URL, nil, nil)
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		var netErr net.Error
+
+		require.True(t, errors.As(err, &netErr))
+		require.True(t, netErr.Timeout())
+	})
+}
+
+//nolint:funlen
+func TestHTTPClient_SourcePolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if target := r.URL.Query().Get("redirect"); target != "" {
+			http.Redirect(w, r, target, http.StatusFound)
+
+			return
+		}
+
+		_, _ = w.Write([]byte("OK"))
+	}))
+
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	get := func(policy SourcePolicy, rawURL string) error {
+		resp, err := NewHTTPClient(NewTimeoutClient(Timeouts{}, policy), policy, HeaderPolicy{}).Get(context.Background(), rawURL, nil, nil)
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		return err
+	}
+
+	t.Run("loopback is rejected after resolving", func(t *testing.T) {
+		err := get(SourcePolicy{}, server.URL)
+
+		require.True(t, errors.Is(err, ErrSourceForbidden))
+		require.True(t, errors.Is(err, domain.ErrForbidden))
+	})
+
+	t.Run("private networks allowed explicitly", func(t *testing.T) {
+		require.Nil(t, get(SourcePolicy{AllowPrivateNetworks: true}, server.URL))
+	})
+
+	t.Run("unsupported scheme", func(t *testing.T) {
+		err := get(SourcePolicy{AllowPrivateNetworks: true}, "file:///etc/passwd")
+
+		require.True(t, errors.Is(err, ErrSourceForbidden))
+	})
+
+	t.Run("denied host", func(t *testing.T) {
+		policy := SourcePolicy{
+			DeniedHosts:          []string{serverURL.Hostname()},
+			AllowPrivateNetworks: true,
+		}
+
+		require.True(t, errors.Is(get(policy, server.URL), ErrSourceForbidden))
+	})
+
+	t.Run("host outside allow list", func(t *testing.T) {
+		policy := SourcePolicy{
+			AllowedHosts:         []string{"*.example.com"},
+			AllowPrivateNetworks: true,
+		}
+
+		require.True(t, errors.Is(get(policy, server.URL), ErrSourceForbidden))
+	})
+
+	t.Run("redirect to a denied host", func(t *testing.T) {
+		policy := SourcePolicy{
+			AllowedHosts:         []string{serverURL.Hostname()},
+			AllowPrivateNetworks: true,
+		}
+
+		err := get(policy, server.URL+"?redirect="+url.QueryEscape("http://metadata.internal/latest"))
+
+		require.True(t, errors.Is(err, ErrSourceForbidden))
+	})
+}
+
+func TestCheckIP(t *testing.T) {
+	for _, addr := range []string{
+		"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254",
+		"100.64.0.1", "0.0.0.0", "0.1.2.3", "192.0.0.170", "198.18.0.1", "198.19.255.255",
+		"240.0.0.1", "255.255.255.255", "::1", "fe80::1", "fd00::1", "::ffff:10.0.0.1",
+		"64:ff9b::a9fe:a9fe", "64:ff9b::7f00:1",
+	} {
+		require.True(t, errors.Is(checkIP(net.ParseIP(addr)), ErrSourceForbidden), addr)
+	}
+
+	for _, addr := range []string{"93.184.216.34", "8.8.8.8", "2606:4700::1111", "198.20.0.1", "192.0.1.1"} {
+		require.Nil(t, checkIP(net.ParseIP(addr)), addr)
+	}
+}
+
+func TestNewTimeoutClient_IgnoresProxy(t *testing.T) {
+	client := NewTimeoutClient(Timeouts{}, SourcePolicy{})
+
+	require.Nil(t, client.Transport.(*http.Transport).Proxy)
+}
@@ -1,50 +1,146 @@
 package downloader
 
 import (
+	"context"
 	"errors"
-	"image"
+	"fmt"
 	"image-previewer/internal/domain"
 	"image-previewer/internal/domain/dto"
-	"image/jpeg"
+	"image-previewer/internal/logging"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
-
-	"github.com/disintegration/imaging"
-	"go.uber.org/zap"
+	"time"
 )
 
 var (
-	ErrResourceUnavailable = errors.New("image resource unavailable")
-	ErrInvalidJpeg         = errors.New("image should have correct jpeg struct")
+	ErrResourceUnavailable = domain.NewError(domain.ErrUpstreamFailure, "image resource unavailable")
+	ErrResourceNotFound    = domain.NewError(domain.ErrUpstreamNotFound, "image resource not found")
+	ErrResourceTimeout     = domain.NewError(domain.ErrUpstreamTimeout, "image resource timed out")
+	ErrResourceTooLarge    = domain.NewError(domain.ErrPayloadTooLarge, "image resource exceeds the size limit")
 )
 
+// relayedHeaders are the origin response headers kept on UpstreamError.
+var relayedHeaders = []string{"Cache-Control", "Retry-After"}
+
 type HTTPDownloader struct {
 	client Client
+	// maxBytes caps the source body, 0 means unlimited
+	maxBytes int64
+	// defaultTTL applies to origins that say nothing about freshness, 0 keeps such images forever
+	defaultTTL time.Duration
 }
 
-func (d *HTTPDownloader) Download(url string, dim dto.ImageDimensions, headers domain.RequestHeaders) (image.Image, error) {
-	resp, err := d.client.Get(url, headers)
+func (d *HTTPDownloader) Download(
+	ctx context.Context,
+	url string,
+	headers domain.RequestHeaders,
+	cached dto.OriginCache,
+) (*dto.SourceImage, error) {
+	resp, err := d.client.Get(ctx, url, headers, conditionalHeaders(cached))
 	if err != nil {
-		return nil, err
+		return nil, wrapClientError(err)
 	}
 
 	defer resp.Body.Close()
 
+	logging.Annotate(ctx, "upstream_status", resp.StatusCode)
+
+	if resp.StatusCode == http.StatusNotModified && cached.Validators() {
+		logging.FromContext(ctx).Debugf("%s not modified", url)
+
+		return &dto.SourceImage{
+			Origin:      originCache(resp, cached, time.Now(), d.defaultTTL),
+			NotModified: true,
+		}, nil
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		return nil, ErrResourceUnavailable
+		return nil, upstreamError(url, resp)
+	}
+
+	if d.maxBytes > 0 && resp.ContentLength > d.maxBytes {
+		return nil, ErrResourceTooLarge
 	}
 
-	img, err := jpeg.Decode(resp.Body)
+	content, err := d.readBody(resp.Body)
 	if err != nil {
-		return nil, ErrInvalidJpeg
+		return nil, err
 	}
 
-	zap.S().Debugf("resizing downloaded image %d x %d", dim.Width, dim.Height)
+	logging.FromContext(ctx).Debugf("downloaded %d bytes from %s", len(content), url)
+
+	return &dto.SourceImage{
+		Content:     content,
+		ContentType: resp.Header.Get("Content-Type"),
+		Origin:      originCache(resp, dto.OriginCache{}, time.Now(), d.defaultTTL),
+	}, nil
+}
+
+// readBody does not trust Content-Length: it may be absent or lie, so the read itself is capped.
+func (d *HTTPDownloader) readBody(body io.Reader) ([]byte, error) {
+	if d.maxBytes <= 0 {
+		content, err := ioutil.ReadAll(body)
+		if err != nil {
+			return nil, wrapClientError(err)
+		}
+
+		return content, nil
+	}
+
+	content, err := ioutil.ReadAll(io.LimitReader(body, d.maxBytes+1))
+	if err != nil {
+		return nil, wrapClientError(err)
+	}
+
+	if int64(len(content)) > d.maxBytes {
+		return nil, ErrResourceTooLarge
+	}
+
+	return content, nil
+}
+
+func upstreamError(url string, resp *http.Response) *domain.UpstreamError {
+	err := &domain.UpstreamError{
+		URL:        url,
+		StatusCode: resp.StatusCode,
+		Header:     make(http.Header),
+		Err:        ErrResourceUnavailable,
+	}
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		err.Err = ErrResourceNotFound
+	}
+
+	for _, name := range relayedHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			err.Header.Set(name, value)
+		}
+	}
+
+	return err
+}
+
+// wrapClientError keeps the transport error message but classifies it as a timeout or a failure.
+func wrapClientError(err error) error {
+	var netErr net.Error
+
+	if errors.Is(err, domain.ErrForbidden) {
+		return err
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return fmt.Errorf("%w: %s", ErrResourceTimeout, err)
+	}
 
-	return imaging.Fill(img, dim.Width, dim.Height, imaging.Center, imaging.Lanczos), nil
+	return fmt.Errorf("%w: %s", ErrResourceUnavailable, err)
 }
 
-func NewHTTPDownloader(c Client) *HTTPDownloader {
+func NewHTTPDownloader(c Client, maxBytes int64, defaultTTL time.Duration) *HTTPDownloader {
 	return &HTTPDownloader{
-		client: c,
+		client:     c,
+		maxBytes:   maxBytes,
+		defaultTTL: defaultTTL,
 	}
 }
@@ -2,12 +2,17 @@ package downloader
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"image-previewer/internal/domain"
 	"image-previewer/internal/domain/dto"
 	"image-previewer/tests/mocks"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
@@ -22,72 +27,164 @@ func TestHttpDownloader_Download(t *testing.T) {
 		client := mocks.NewMockClient(ctrl)
 		client.
 			EXPECT().
-			Get(gomock.Any(), gomock.Any()).
+			Get(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 			Return(&http.Response{
 				StatusCode: http.StatusNotFound,
+				Header:     http.Header{"Cache-Control": []string{"max-age=60"}, "Set-Cookie": []string{"a=b"}},
 				Body:       ioutil.NopCloser(bytes.NewReader(nil)),
 			}, nil)
 
-		img, err := NewHTTPDownloader(client).Download(
-			"http://yandex.ru/test.jpg",
-			dto.ImageDimensions{
-				Width:  0,
-				Height: 0,
-			},
-			nil,
-		)
-
-		require.Nil(t, img)
-		require.Equal(t, ErrResourceUnavailable, err)
-	})
+		src, err := NewHTTPDownloader(client, 0, 0).Download(context.Background(), "http://yandex.ru/test.jpg", nil, dto.OriginCache{})
+
+		require.Nil(t, src)
+		require.True(t, errors.Is(err, ErrResourceNotFound))
+		require.True(t, errors.Is(err, domain.ErrUpstreamNotFound))
 
-	t.Run("unsupported image mime type", func(t *testing.T) {
-		testFile, _ := os.Open("../../../tests/data/_gopher_original_1024x504.png")
+		var upstreamErr *domain.UpstreamError
+
+		require.True(t, errors.As(err, &upstreamErr))
+		require.Equal(t, http.StatusNotFound, upstreamErr.StatusCode)
+		require.Equal(t, "http://yandex.ru/test.jpg", upstreamErr.URL)
+		require.Equal(t, http.Header{"Cache-Control": []string{"max-age=60"}}, upstreamErr.Header)
+	})
 
+	t.Run("remote server failure", func(t *testing.T) {
 		client := mocks.NewMockClient(ctrl)
 		client.
 			EXPECT().
-			Get(gomock.Any(), gomock.Any()).
+			Get(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 			Return(&http.Response{
-				StatusCode: http.StatusOK,
-				Body:       ioutil.NopCloser(testFile),
+				StatusCode: http.StatusServiceUnavailable,
+				Body:       ioutil.NopCloser(bytes.NewReader(nil)),
 			}, nil)
 
-		img, err := NewHTTPDownloader(client).Download(
-			"http://yandex.ru/test.jpg",
-			dto.ImageDimensions{
-				Width:  0,
-				Height: 0,
-			},
-			nil,
-		)
-
-		require.Nil(t, img)
-		require.Error(t, ErrInvalidJpeg, err)
+		src, err := NewHTTPDownloader(client, 0, 0).Download(context.Background(), "http://yandex.ru/test.jpg", nil, dto.OriginCache{})
+
+		require.Nil(t, src)
+		require.True(t, errors.Is(err, ErrResourceUnavailable))
+		require.True(t, errors.Is(err, domain.ErrUpstreamFailure))
+		require.Equal(t, "image resource unavailable: http://yandex.ru/test.jpg responded with 503 Service Unavailable", err.Error())
+	})
+
+	t.Run("client error", func(t *testing.T) {
+		clientErr := errors.New("connection refused")
+
+		client := mocks.NewMockClient(ctrl)
+		client.
+			EXPECT().
+			Get(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(nil, clientErr)
+
+		src, err := NewHTTPDownloader(client, 0, 0).Download(context.Background(), "http://yandex.ru/test.jpg", nil, dto.OriginCache{})
+
+		require.Nil(t, src)
+		require.True(t, errors.Is(err, ErrResourceUnavailable))
+		require.Contains(t, err.Error(), clientErr.Error())
+	})
+
+	t.Run("client timeout", func(t *testing.T) {
+		client := mocks.NewMockClient(ctrl)
+		client.
+			EXPECT().
+			Get(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(nil, &url.Error{Op: "Get", URL: "http://yandex.ru/test.jpg", Err: context.DeadlineExceeded})
+
+		src, err := NewHTTPDownloader(client, 0, 0).Download(context.Background(), "http://yandex.ru/test.jpg", nil, dto.OriginCache{})
+
+		require.Nil(t, src)
+		require.True(t, errors.Is(err, domain.ErrUpstreamTimeout))
 	})
 
 	t.Run("response should be valid", func(t *testing.T) {
 		testFile, _ := os.Open("../../../tests/data/_gopher_original_1024x504.jpg")
+		expected, _ := ioutil.ReadFile("../../../tests/data/_gopher_original_1024x504.jpg")
 
 		client := mocks.NewMockClient(ctrl)
 		client.
 			EXPECT().
-			Get(gomock.Any(), gomock.Any()).
+			Get(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 			Return(&http.Response{
 				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"image/jpeg"}},
 				Body:       ioutil.NopCloser(testFile),
 			}, nil)
 
-		img, err := NewHTTPDownloader(client).Download(
-			"http://yandex.ru/test.jpg",
-			dto.ImageDimensions{
-				Width:  200,
-				Height: 200,
-			},
-			nil,
-		)
+		src, err := NewHTTPDownloader(client, 0, 0).Download(context.Background(), "http://yandex.ru/test.jpg", nil, dto.OriginCache{})
+
+		require.Nil(t, err)
+		require.Equal(t, expected, src.Content)
+		require.Equal(t, "image/jpeg", src.ContentType)
+	})
+
+	t.Run("declared length over the limit", func(t *testing.T) {
+		client := mocks.NewMockClient(ctrl)
+		client.
+			EXPECT().
+			Get(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(&http.Response{
+				StatusCode:    http.StatusOK,
+				ContentLength: 11,
+				Body:          ioutil.NopCloser(bytes.NewBufferString("hello world")),
+			}, nil)
+
+		src, err := NewHTTPDownloader(client, 10, 0).Download(context.Background(), "http://yandex.ru/test.jpg", nil, dto.OriginCache{})
+
+		require.Nil(t, src)
+		require.Equal(t, ErrResourceTooLarge, err)
+	})
+
+	t.Run("body over the limit without length", func(t *testing.T) {
+		client := mocks.NewMockClient(ctrl)
+		client.
+			EXPECT().
+			Get(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(&http.Response{
+				StatusCode:    http.StatusOK,
+				ContentLength: -1,
+				Body:          ioutil.NopCloser(bytes.NewBufferString("hello world")),
+			}, nil)
+
+		src, err := NewHTTPDownloader(client, 10, 0).Download(context.Background(), "http://yandex.ru/test.jpg", nil, dto.OriginCache{})
+
+		require.Nil(t, src)
+		require.True(t, errors.Is(err, domain.ErrPayloadTooLarge))
+	})
+
+	t.Run("body within the limit", func(t *testing.T) {
+		client := mocks.NewMockClient(ctrl)
+		client.
+			EXPECT().
+			Get(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(&http.Response{
+				StatusCode:    http.StatusOK,
+				ContentLength: -1,
+				Body:          ioutil.NopCloser(bytes.NewBufferString("hello")),
+			}, nil)
+
+		src, err := NewHTTPDownloader(client, 5, 0).Download(context.Background(), "http://yandex.ru/test.jpg", nil, dto.OriginCache{})
+
+		require.Nil(t, err)
+		require.Equal(t, []byte("hello"), src.Content)
+	})
+	t.Run("revalidation", func(t *testing.T) {
+		cached := dto.OriginCache{ETag: `"v1"`}
+
+		client := mocks.NewMockClient(ctrl)
+		client.
+			EXPECT().
+			Get(gomock.Any(), gomock.Any(), gomock.Any(), http.Header{"If-None-Match": {`"v1"`}}).
+			Return(&http.Response{
+				StatusCode: http.StatusNotModified,
+				Header:     http.Header{"Cache-Control": {"max-age=60"}},
+				Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+			}, nil)
+
+		src, err := NewHTTPDownloader(client, 0, 0).Download(context.Background(), "http://yandex.ru/test.jpg", nil, cached)
 
-		require.NotNil(t, img)
 		require.Nil(t, err)
+		require.True(t, src.NotModified)
+		require.Equal(t, `"v1"`, src.Origin.ETag)
+		require.False(t, src.Origin.Stale(time.Now()))
+		require.True(t, src.Origin.Stale(time.Now().Add(time.Minute)))
 	})
 }
@@ -0,0 +1,89 @@
+package downloader
+
+import (
+	"image-previewer/internal/domain/dto"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// originCache reads how long the origin lets us keep its image: Cache-Control max-age first,
+// then Expires, then defaultTTL. Validators missing from a 304 are taken from the previous copy.
+func originCache(resp *http.Response, previous dto.OriginCache, now time.Time, defaultTTL time.Duration) dto.OriginCache {
+	cache := dto.OriginCache{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+
+	if cache.ETag == "" {
+		cache.ETag = previous.ETag
+	}
+
+	if cache.LastModified == "" {
+		cache.LastModified = previous.LastModified
+	}
+
+	if defaultTTL > 0 {
+		cache.Expires = now.Add(defaultTTL)
+	}
+
+	directives := cacheControl(resp.Header.Get("Cache-Control"))
+
+	_, noStore := directives["no-store"]
+	_, noCache := directives["no-cache"]
+
+	switch {
+	case noStore || noCache:
+		cache.Expires = now
+	case directives["max-age"] != "":
+		if seconds, err := strconv.ParseInt(directives["max-age"], 10, 64); err == nil {
+			cache.Expires = now.Add(time.Duration(seconds) * time.Second)
+		}
+	case resp.Header.Get("Expires") != "":
+		// an invalid date means already expired
+		cache.Expires = now
+
+		if expires, err := http.ParseTime(resp.Header.Get("Expires")); err == nil {
+			cache.Expires = expires
+		}
+	}
+
+	return cache
+}
+
+func conditionalHeaders(cached dto.OriginCache) http.Header {
+	if !cached.Validators() {
+		return nil
+	}
+
+	headers := make(http.Header)
+
+	if cached.ETag != "" {
+		headers.Set("If-None-Match", cached.ETag)
+	}
+
+	if cached.LastModified != "" {
+		headers.Set("If-Modified-Since", cached.LastModified)
+	}
+
+	return headers
+}
+
+func cacheControl(header string) map[string]string {
+	directives := make(map[string]string)
+
+	for _, directive := range strings.Split(header, ",") {
+		name, value := directive, ""
+
+		if i := strings.Index(directive, "="); i >= 0 {
+			name, value = directive[:i], strings.Trim(strings.TrimSpace(directive[i+1:]), `"`)
+		}
+
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			directives[name] = value
+		}
+	}
+
+	return directives
+}
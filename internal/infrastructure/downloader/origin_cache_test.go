@@ -0,0 +1,69 @@
+package downloader
+
+import (
+	"image-previewer/internal/domain/dto"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOriginCache(t *testing.T) {
+	now := time.Date(2020, 9, 1, 10, 0, 0, 0, time.UTC)
+	response := func(header map[string]string) *http.Response {
+		resp := &http.Response{Header: make(http.Header)}
+
+		for name, value := range header {
+			resp.Header.Set(name, value)
+		}
+
+		return resp
+	}
+
+	cases := []struct {
+		name     string
+		header   map[string]string
+		expected time.Time
+	}{
+		{"nothing said", nil, now.Add(time.Hour)},
+		{"max-age", map[string]string{"Cache-Control": "public, max-age=60"}, now.Add(time.Minute)},
+		{"max-age wins over expires", map[string]string{
+			"Cache-Control": "max-age=60",
+			"Expires":       "Wed, 02 Sep 2020 10:00:00 GMT",
+		}, now.Add(time.Minute)},
+		{"expires", map[string]string{"Expires": "Wed, 02 Sep 2020 10:00:00 GMT"}, now.Add(24 * time.Hour)},
+		{"invalid expires", map[string]string{"Expires": "0"}, now},
+		{"no-cache", map[string]string{"Cache-Control": "No-Cache, max-age=60"}, now},
+		{"no-store", map[string]string{"Cache-Control": "no-store"}, now},
+	}
+
+	for _, c := range cases {
+		cache := originCache(response(c.header), dto.OriginCache{}, now, time.Hour)
+
+		require.True(t, c.expected.Equal(cache.Expires), c.name)
+	}
+
+	t.Run("no default ttl", func(t *testing.T) {
+		require.True(t, originCache(response(nil), dto.OriginCache{}, now, 0).Expires.IsZero())
+	})
+
+	t.Run("validators", func(t *testing.T) {
+		cache := originCache(response(map[string]string{"ETag": `"v2"`}), dto.OriginCache{
+			ETag:         `"v1"`,
+			LastModified: "Tue, 01 Sep 2020 09:00:00 GMT",
+		}, now, 0)
+
+		require.Equal(t, `"v2"`, cache.ETag)
+		require.Equal(t, "Tue, 01 Sep 2020 09:00:00 GMT", cache.LastModified)
+	})
+}
+
+func TestConditionalHeaders(t *testing.T) {
+	require.Nil(t, conditionalHeaders(dto.OriginCache{Expires: time.Now()}))
+
+	require.Equal(t, http.Header{
+		"If-None-Match":     {`"v1"`},
+		"If-Modified-Since": {"Tue, 01 Sep 2020 09:00:00 GMT"},
+	}, conditionalHeaders(dto.OriginCache{ETag: `"v1"`, LastModified: "Tue, 01 Sep 2020 09:00:00 GMT"}))
+}
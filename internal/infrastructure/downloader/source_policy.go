@@ -0,0 +1,129 @@
+package downloader
+
+import (
+	"fmt"
+	"image-previewer/internal/domain"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+)
+
+var ErrSourceForbidden = domain.NewError(domain.ErrForbidden, "image source is not allowed")
+
+// privateNetworks are ranges not covered by the net.IP predicates used in checkIP.
+var privateNetworks = mustParseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"100.64.0.0/10",
+	"192.0.0.0/24",
+	"198.18.0.0/15",
+	"240.0.0.0/4",
+	"fc00::/7",
+	// NAT64 reaches any IPv4 address through the gateway, the metadata service included
+	"64:ff9b::/96",
+)
+
+// SourcePolicy decides which origins may be fetched. Host patterns use path.Match syntax,
+// e.g. "*.example.com", an empty allow list lets any host through that is not denied.
+type SourcePolicy struct {
+	AllowedHosts []string
+	DeniedHosts  []string
+	// AllowPrivateNetworks disables the dial guard for loopback, private and link-local addresses.
+	AllowPrivateNetworks bool
+}
+
+func (p SourcePolicy) Validate() error {
+	for _, pattern := range append(append([]string{}, p.AllowedHosts...), p.DeniedHosts...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("bad host pattern %q: %w", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// CheckURL runs before every request including redirects, addresses are checked later on dialing
+// because only then the host is resolved.
+func (p SourcePolicy) CheckURL(uri *url.URL) error {
+	if uri.Scheme != "http" && uri.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q", ErrSourceForbidden, uri.Scheme)
+	}
+
+	host := strings.ToLower(uri.Hostname())
+
+	if host == "" {
+		return fmt.Errorf("%w: empty host", ErrSourceForbidden)
+	}
+
+	if matchHost(p.DeniedHosts, host) {
+		return fmt.Errorf("%w: host %s is denied", ErrSourceForbidden, host)
+	}
+
+	if len(p.AllowedHosts) > 0 && !matchHost(p.AllowedHosts, host) {
+		return fmt.Errorf("%w: host %s is not allowed", ErrSourceForbidden, host)
+	}
+
+	return nil
+}
+
+// control is a net.Dialer hook, it sees the address after DNS resolution so rebinding tricks don't help.
+func (p SourcePolicy) control(_, address string, _ syscall.RawConn) error {
+	if p.AllowPrivateNetworks {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	return checkIP(net.ParseIP(host))
+}
+
+func checkIP(ip net.IP) error {
+	if ip == nil {
+		return fmt.Errorf("%w: unresolved address", ErrSourceForbidden)
+	}
+
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified() || ip.IsMulticast() {
+		return fmt.Errorf("%w: address %s is not public", ErrSourceForbidden, ip)
+	}
+
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return fmt.Errorf("%w: address %s is not public", ErrSourceForbidden, ip)
+		}
+	}
+
+	return nil
+}
+
+func matchHost(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), host); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks
+}
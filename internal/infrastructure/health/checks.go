@@ -0,0 +1,90 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+)
+
+var (
+	ErrShuttingDown = errors.New("server is shutting down")
+	// errStatfsUnsupported is returned by diskFree where the platform can't tell free space.
+	errStatfsUnsupported = errors.New("free disk space is not available on this platform")
+)
+
+// Check is a named readiness probe, a nil error means the dependency is fine.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// DirWritable proves the directory accepts new files by creating and removing a probe.
+func DirWritable(dir string) Check {
+	return Check{
+		Name: "cache_dir",
+		Run: func(ctx context.Context) error {
+			// dotfiles are skipped when the preview cache is restored
+			file, err := ioutil.TempFile(dir, ".healthcheck.")
+			if err != nil {
+				return fmt.Errorf("%s is not writable: %w", dir, err)
+			}
+
+			file.Close()
+
+			return os.Remove(file.Name())
+		},
+	}
+}
+
+// FreeSpace fails when the filesystem holding dir has less than minBytes available,
+// 0 turns the check off.
+func FreeSpace(dir string, minBytes uint64) Check {
+	return Check{
+		Name: "disk_space",
+		Run: func(ctx context.Context) error {
+			if minBytes == 0 {
+				return nil
+			}
+
+			free, err := diskFree(dir)
+			if err == errStatfsUnsupported {
+				return nil
+			}
+
+			if err != nil {
+				return fmt.Errorf("failed to stat %s: %w", dir, err)
+			}
+
+			if free < minBytes {
+				return fmt.Errorf("%d bytes free on %s, at least %d required", free, dir, minBytes)
+			}
+
+			return nil
+		},
+	}
+}
+
+// ShutdownFlag turns readiness off once the server starts draining.
+type ShutdownFlag struct {
+	set int32
+}
+
+func (f *ShutdownFlag) Set() {
+	atomic.StoreInt32(&f.set, 1)
+}
+
+func (f *ShutdownFlag) Check() Check {
+	return Check{
+		Name: "shutdown",
+		Run: func(ctx context.Context) error {
+			if atomic.LoadInt32(&f.set) != 0 {
+				return ErrShuttingDown
+			}
+
+			return nil
+		},
+	}
+}
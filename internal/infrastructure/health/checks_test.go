@@ -0,0 +1,44 @@
+package health
+
+import (
+	"context"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirWritable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "health")
+	require.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	require.Nil(t, DirWritable(dir).Run(context.Background()))
+
+	files, err := ioutil.ReadDir(dir)
+	require.Nil(t, err)
+	require.Len(t, files, 0)
+
+	require.NotNil(t, DirWritable(filepath.Join(dir, "missing")).Run(context.Background()))
+}
+
+func TestFreeSpace(t *testing.T) {
+	require.Nil(t, FreeSpace(os.TempDir(), 0).Run(context.Background()))
+	require.Nil(t, FreeSpace(os.TempDir(), 1).Run(context.Background()))
+	require.NotNil(t, FreeSpace(os.TempDir(), math.MaxUint64).Run(context.Background()))
+}
+
+func TestShutdownFlag(t *testing.T) {
+	flag := &ShutdownFlag{}
+	check := flag.Check()
+
+	require.Nil(t, check.Run(context.Background()))
+
+	flag.Set()
+
+	require.Equal(t, ErrShuttingDown, check.Run(context.Background()))
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package health
+
+func diskFree(dir string) (uint64, error) {
+	return 0, errStatfsUnsupported
+}
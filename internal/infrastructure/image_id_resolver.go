@@ -1,24 +1,94 @@
 package infrastructure
 
 import (
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
-	"hash/fnv"
 	"image-previewer/internal/domain"
 	"image-previewer/internal/domain/dto"
+	"net/http"
 )
 
+// keyVersion prefixes every id, bump it whenever keySpec changes so stale previews are never reused.
+const keyVersion = "v3"
+
+// keySpec is everything a preview depends on, normalized so that equivalent requests share a key.
+type keySpec struct {
+	URL        string              `json:"url"`
+	Mode       dto.ResizeMode      `json:"mode"`
+	Width      int                 `json:"width"`
+	Height     int                 `json:"height"`
+	Gravity    dto.Gravity         `json:"gravity,omitempty"`
+	Background string              `json:"background,omitempty"`
+	Raw        bool                `json:"raw,omitempty"`
+	Filters    string              `json:"filters,omitempty"`
+	Format     dto.ImageFormat     `json:"format"`
+	Resample   string              `json:"resample"`
+	Headers    map[string][]string `json:"headers,omitempty"`
+}
+
 type ImageIDResolver struct {
+	// resample is the configured resample filter, previews rendered with another one are not reused
+	resample    string
+	varyHeaders []string
 }
 
-func (r *ImageIDResolver) ResolveImageID(url string, dim dto.ImageDimensions) domain.ImageID {
-	h := fnv.New32a()
-	_, _ = h.Write([]byte(url))
+func (r *ImageIDResolver) ResolveImageID(
+	url string,
+	headers domain.RequestHeaders,
+	t dto.Transformation,
+	format dto.ImageFormat,
+) domain.ImageID {
+	spec := keySpec{
+		URL:      url,
+		Mode:     t.Mode,
+		Width:    t.Dimensions.Width,
+		Height:   t.Dimensions.Height,
+		Raw:      t.IgnoreOrientation,
+		Filters:  t.Filters.String(),
+		Format:   format,
+		Resample: r.resample,
+	}
+
+	if t.Mode.Crops() {
+		spec.Gravity = t.Gravity
+		if spec.Gravity == "" {
+			spec.Gravity = dto.GravityCenter
+		}
+	}
+
+	if t.Mode == dto.ModeThumbnail {
+		bg := t.Background
+		spec.Background = fmt.Sprintf("%02x%02x%02x%02x", bg.R, bg.G, bg.B, bg.A)
+	}
 
-	imageID := fmt.Sprintf("%d_%dx%d", int(h.Sum32()), dim.Width, dim.Height)
+	for _, name := range r.varyHeaders {
+		if values, ok := http.Header(headers)[name]; ok {
+			if spec.Headers == nil {
+				spec.Headers = make(map[string][]string)
+			}
 
-	return domain.ImageID(imageID)
+			spec.Headers[name] = values
+		}
+	}
+
+	// marshalling a struct of plain fields can't fail, and json keeps field boundaries unambiguous
+	data, _ := json.Marshal(spec)
+
+	return domain.ImageID(fmt.Sprintf("%s_%x.%s", keyVersion, sha256.Sum256(data), format))
 }
 
-func NewImageIDResolver() *ImageIDResolver {
-	return &ImageIDResolver{}
+// NewImageIDResolver makes the resample filter name and the given request headers part of the key,
+// e.g. Authorization when it is forwarded to origins serving private images.
+func NewImageIDResolver(resample string, varyHeaders []string) *ImageIDResolver {
+	canonical := make([]string, 0, len(varyHeaders))
+
+	for _, name := range varyHeaders {
+		canonical = append(canonical, http.CanonicalHeaderKey(name))
+	}
+
+	return &ImageIDResolver{
+		resample:    resample,
+		varyHeaders: canonical,
+	}
 }
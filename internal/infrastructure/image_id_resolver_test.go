@@ -3,6 +3,7 @@ package infrastructure
 import (
 	"image-previewer/internal/domain"
 	"image-previewer/internal/domain/dto"
+	"image/color"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -10,14 +11,136 @@ import (
 
 func TestImageIdResolver_ResolveImageId(t *testing.T) {
 	t.Run("resolve id should return valid string", func(t *testing.T) {
-		actualID := NewImageIDResolver().ResolveImageID(
+		actualID := NewImageIDResolver("lanczos", nil).ResolveImageID(
 			"http://ya.ru/test.jpg",
-			dto.ImageDimensions{
+			nil,
+			dto.Transformation{
+				Mode: dto.ModeFill,
+				Dimensions: dto.ImageDimensions{
+					Width:  100,
+					Height: 500,
+				},
+			},
+			dto.FormatJPEG,
+		)
+
+		require.Regexp(t, `^v3_[0-9a-f]{64}\.jpeg$`, string(actualID))
+	})
+
+	t.Run("output format should be part of id", func(t *testing.T) {
+		r := NewImageIDResolver("lanczos", nil)
+		tr := dto.Transformation{
+			Mode: dto.ModeFill,
+			Dimensions: dto.ImageDimensions{
+				Width:  100,
+				Height: 500,
+			},
+		}
+
+		require.NotEqual(
+			t,
+			r.ResolveImageID("http://ya.ru/test.jpg", nil, tr, dto.FormatJPEG),
+			r.ResolveImageID("http://ya.ru/test.jpg", nil, tr, dto.FormatPNG),
+		)
+	})
+
+	t.Run("resample filter should be part of id", func(t *testing.T) {
+		tr := dto.Transformation{
+			Mode: dto.ModeFill,
+			Dimensions: dto.ImageDimensions{
 				Width:  100,
 				Height: 500,
 			},
+		}
+
+		require.NotEqual(
+			t,
+			NewImageIDResolver("lanczos", nil).ResolveImageID("http://ya.ru/test.jpg", nil, tr, dto.FormatJPEG),
+			NewImageIDResolver("box", nil).ResolveImageID("http://ya.ru/test.jpg", nil, tr, dto.FormatJPEG),
 		)
+	})
+
+	t.Run("mode, gravity, orientation and background should be part of id", func(t *testing.T) {
+		r := NewImageIDResolver("lanczos", nil)
+		dim := dto.ImageDimensions{
+			Width:  100,
+			Height: 500,
+		}
+
+		fill := r.ResolveImageID("http://ya.ru/test.jpg", nil, dto.Transformation{Mode: dto.ModeFill, Dimensions: dim}, dto.FormatJPEG)
+		fit := r.ResolveImageID("http://ya.ru/test.jpg", nil, dto.Transformation{Mode: dto.ModeFit, Dimensions: dim}, dto.FormatJPEG)
+		white := r.ResolveImageID("http://ya.ru/test.jpg", nil, dto.Transformation{
+			Mode:       dto.ModeThumbnail,
+			Dimensions: dim,
+			Background: color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+		}, dto.FormatJPEG)
+		black := r.ResolveImageID("http://ya.ru/test.jpg", nil, dto.Transformation{
+			Mode:       dto.ModeThumbnail,
+			Dimensions: dim,
+			Background: color.NRGBA{A: 255},
+		}, dto.FormatJPEG)
+
+		smart := r.ResolveImageID("http://ya.ru/test.jpg", nil, dto.Transformation{
+			Mode:       dto.ModeFill,
+			Dimensions: dim,
+			Gravity:    dto.GravitySmart,
+		}, dto.FormatJPEG)
+
+		raw := r.ResolveImageID("http://ya.ru/test.jpg", nil, dto.Transformation{
+			Mode:              dto.ModeFill,
+			Dimensions:        dim,
+			IgnoreOrientation: true,
+		}, dto.FormatJPEG)
+
+		require.NotEqual(t, fill, fit)
+		require.NotEqual(t, fill, raw)
+		require.NotEqual(t, fill, smart)
+		require.NotEqual(t, white, black)
+		require.Equal(t, fill, r.ResolveImageID("http://ya.ru/test.jpg", nil, dto.Transformation{
+			Mode:       dto.ModeFill,
+			Dimensions: dim,
+			Gravity:    dto.GravityCenter,
+			Background: color.NRGBA{A: 255},
+		}, dto.FormatJPEG), "default gravity and unused background should not change id")
+	})
+
+	t.Run("filters should be part of id", func(t *testing.T) {
+		r := NewImageIDResolver("lanczos", nil)
+		resolve := func(filters dto.Filters) domain.ImageID {
+			return r.ResolveImageID("http://ya.ru/test.jpg", nil, dto.Transformation{
+				Mode: dto.ModeFill,
+				Dimensions: dto.ImageDimensions{
+					Width:  100,
+					Height: 500,
+				},
+				Filters: filters,
+			}, dto.FormatJPEG)
+		}
+
+		blurGray := resolve(dto.Filters{{Type: dto.FilterBlur, Amount: 3}, {Type: dto.FilterGrayscale}})
+		grayBlur := resolve(dto.Filters{{Type: dto.FilterGrayscale}, {Type: dto.FilterBlur, Amount: 3}})
+
+		require.NotEqual(t, resolve(nil), blurGray)
+		require.NotEqual(t, blurGray, grayBlur)
+		require.Equal(t, blurGray, resolve(dto.Filters{{Type: dto.FilterBlur, Amount: 3}, {Type: dto.FilterGrayscale}}))
+	})
+	t.Run("url and vary headers should be part of id", func(t *testing.T) {
+		r := NewImageIDResolver("lanczos", []string{"authorization"})
+		tr := dto.Transformation{
+			Mode: dto.ModeFit,
+			Dimensions: dto.ImageDimensions{
+				Width:  100,
+				Height: 500,
+			},
+		}
+
+		alice := domain.RequestHeaders{"Authorization": {"Bearer alice"}, "User-Agent": {"curl"}}
+		bob := domain.RequestHeaders{"Authorization": {"Bearer bob"}, "User-Agent": {"curl"}}
+		aliceFirefox := domain.RequestHeaders{"Authorization": {"Bearer alice"}, "User-Agent": {"firefox"}}
 
-		require.Equal(t, domain.ImageID("1864972448_100x500"), actualID)
+		require.NotEqual(t, r.ResolveImageID("http://ya.ru/a.jpg", nil, tr, dto.FormatJPEG), r.ResolveImageID("http://ya.ru/b.jpg", nil, tr, dto.FormatJPEG))
+		require.NotEqual(t, r.ResolveImageID("http://ya.ru/a.jpg", alice, tr, dto.FormatJPEG), r.ResolveImageID("http://ya.ru/a.jpg", bob, tr, dto.FormatJPEG))
+		require.NotEqual(t, r.ResolveImageID("http://ya.ru/a.jpg", alice, tr, dto.FormatJPEG), r.ResolveImageID("http://ya.ru/a.jpg", nil, tr, dto.FormatJPEG))
+		require.Equal(t, r.ResolveImageID("http://ya.ru/a.jpg", alice, tr, dto.FormatJPEG), r.ResolveImageID("http://ya.ru/a.jpg", aliceFirefox, tr, dto.FormatJPEG))
 	})
 }
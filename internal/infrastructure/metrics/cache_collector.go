@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CacheStats is what the cache collector reads on every scrape.
+type CacheStats interface {
+	Len() int
+	Size() int64
+	Evictions() uint64
+}
+
+var (
+	cacheEntriesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "cache", "entries"),
+		"Number of previews in the cache.",
+		nil, nil,
+	)
+	cacheBytesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "cache", "bytes"),
+		"Total size of cached previews in bytes.",
+		nil, nil,
+	)
+	cacheEvictionsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "cache", "evictions_total"),
+		"Previews evicted from the cache.",
+		nil, nil,
+	)
+)
+
+// CacheCollector exposes the current state of the preview cache, values are read at scrape time.
+type CacheCollector struct {
+	stats CacheStats
+}
+
+func (c *CacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheEntriesDesc
+	ch <- cacheBytesDesc
+	ch <- cacheEvictionsDesc
+}
+
+func (c *CacheCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(cacheEntriesDesc, prometheus.GaugeValue, float64(c.stats.Len()))
+	ch <- prometheus.MustNewConstMetric(cacheBytesDesc, prometheus.GaugeValue, float64(c.stats.Size()))
+	ch <- prometheus.MustNewConstMetric(cacheEvictionsDesc, prometheus.CounterValue, float64(c.stats.Evictions()))
+}
+
+func NewCacheCollector(stats CacheStats) *CacheCollector {
+	return &CacheCollector{
+		stats: stats,
+	}
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"image-previewer/internal/domain"
+	"image-previewer/internal/domain/dto"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Downloader observes status codes, sizes and durations of the wrapped downloader.
+type Downloader struct {
+	next    domain.Downloader
+	metrics *Metrics
+}
+
+func (d *Downloader) Download(
+	ctx context.Context,
+	url string,
+	headers domain.RequestHeaders,
+	cached dto.OriginCache,
+) (*dto.SourceImage, error) {
+	start := time.Now()
+	src, err := d.next.Download(ctx, url, headers, cached)
+
+	d.metrics.downloadDuration.Observe(time.Since(start).Seconds())
+	d.metrics.downloads.WithLabelValues(downloadStatus(src, err)).Inc()
+
+	if err == nil {
+		d.metrics.downloadBytes.Add(float64(len(src.Content)))
+	}
+
+	return src, err
+}
+
+// downloadStatus is the origin status code, errors raised before a response are labeled as error.
+func downloadStatus(src *dto.SourceImage, err error) string {
+	var upstreamErr *domain.UpstreamError
+
+	switch {
+	case errors.As(err, &upstreamErr):
+		return strconv.Itoa(upstreamErr.StatusCode)
+	case err != nil:
+		return "error"
+	case src.NotModified:
+		return strconv.Itoa(http.StatusNotModified)
+	default:
+		return strconv.Itoa(http.StatusOK)
+	}
+}
+
+func NewDownloader(next domain.Downloader, m *Metrics) *Downloader {
+	return &Downloader{
+		next:    next,
+		metrics: m,
+	}
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"image"
+	"image-previewer/internal/domain"
+	"image-previewer/internal/domain/dto"
+	"time"
+)
+
+// ImageProcessor observes how long each stage of the wrapped processor takes.
+type ImageProcessor struct {
+	next    domain.ImageProcessor
+	metrics *Metrics
+}
+
+func (p *ImageProcessor) Decode(src *dto.SourceImage, autoOrient bool) (image.Image, error) {
+	defer p.observe(stageDecode, time.Now())
+
+	return p.next.Decode(src, autoOrient)
+}
+
+func (p *ImageProcessor) Transform(img image.Image, t dto.Transformation) (image.Image, error) {
+	defer p.observe(stageResize, time.Now())
+
+	return p.next.Transform(img, t)
+}
+
+func (p *ImageProcessor) Encode(img image.Image, format dto.ImageFormat) (*dto.Preview, error) {
+	defer p.observe(stageEncode, time.Now())
+
+	return p.next.Encode(img, format)
+}
+
+func (p *ImageProcessor) observe(stage string, start time.Time) {
+	p.metrics.processingDuration.WithLabelValues(stage).Observe(time.Since(start).Seconds())
+}
+
+func NewImageProcessor(next domain.ImageProcessor, m *Metrics) *ImageProcessor {
+	return &ImageProcessor{
+		next:    next,
+		metrics: m,
+	}
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "previewer"
+
+// Processing stages observed by the processing duration histogram.
+const (
+	stageDecode = "decode"
+	stageResize = "resize"
+	stageEncode = "encode"
+)
+
+// Metrics holds the collectors shared by the instrumentation wrappers.
+type Metrics struct {
+	cacheLookups       *prometheus.CounterVec
+	downloads          *prometheus.CounterVec
+	downloadBytes      prometheus.Counter
+	downloadDuration   prometheus.Histogram
+	processingDuration *prometheus.HistogramVec
+}
+
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		cacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "cache",
+			Name:      "lookups_total",
+			Help:      "Preview cache lookups by result, hit or miss.",
+		}, []string{"result"}),
+		downloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "download",
+			Name:      "requests_total",
+			Help:      "Source downloads by origin status code, or error when there was no response.",
+		}, []string{"status"}),
+		downloadBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "download",
+			Name:      "bytes_total",
+			Help:      "Bytes of source images downloaded.",
+		}),
+		downloadDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "download",
+			Name:      "duration_seconds",
+			Help:      "Time spent downloading source images.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		processingDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "processing",
+			Name:      "duration_seconds",
+			Help:      "Time spent in each processing stage: decode, resize or encode.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+		}, []string{"stage"}),
+	}
+
+	reg.MustRegister(
+		m.cacheLookups,
+		m.downloads,
+		m.downloadBytes,
+		m.downloadDuration,
+		m.processingDuration,
+	)
+
+	return m
+}
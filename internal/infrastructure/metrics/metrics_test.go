@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image-previewer/internal/application/handlers"
+	"image-previewer/internal/domain"
+	"image-previewer/internal/domain/dto"
+	"image-previewer/tests/mocks"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStats struct{}
+
+func (fakeStats) Len() int          { return 3 }
+func (fakeStats) Size() int64       { return 1024 }
+func (fakeStats) Evictions() uint64 { return 7 }
+
+func TestPreviewRepository_FindOne(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMetrics(prometheus.NewRegistry())
+	next := mocks.NewMockPreviewRepository(ctrl)
+	r := NewPreviewRepository(next, m)
+
+	next.EXPECT().FindOne(gomock.Any(), domain.ImageID("hit")).Return(&dto.PreviewStream{}, nil)
+	next.EXPECT().FindOne(gomock.Any(), domain.ImageID("miss")).Return(nil, handlers.ErrNotFound).Times(2)
+	next.EXPECT().FindOne(gomock.Any(), domain.ImageID("broken")).Return(nil, errors.New("broken"))
+
+	_, _ = r.FindOne(context.Background(), "hit")
+	_, _ = r.FindOne(context.Background(), "miss")
+	_, _ = r.FindOne(context.Background(), "miss")
+	_, err := r.FindOne(context.Background(), "broken")
+
+	require.NotNil(t, err)
+	require.Equal(t, 1.0, testutil.ToFloat64(m.cacheLookups.WithLabelValues("hit")))
+	require.Equal(t, 2.0, testutil.ToFloat64(m.cacheLookups.WithLabelValues("miss")))
+}
+
+func TestDownloader_Download(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMetrics(prometheus.NewRegistry())
+	next := mocks.NewMockDownloader(ctrl)
+	d := NewDownloader(next, m)
+
+	next.EXPECT().Download(gomock.Any(), "ok", gomock.Any(), gomock.Any()).
+		Return(&dto.SourceImage{Content: make([]byte, 100)}, nil)
+	next.EXPECT().Download(gomock.Any(), "cached", gomock.Any(), gomock.Any()).
+		Return(&dto.SourceImage{NotModified: true}, nil)
+	next.EXPECT().Download(gomock.Any(), "missing", gomock.Any(), gomock.Any()).
+		Return(nil, &domain.UpstreamError{StatusCode: http.StatusNotFound, Err: domain.ErrUpstreamNotFound})
+	next.EXPECT().Download(gomock.Any(), "timeout", gomock.Any(), gomock.Any()).
+		Return(nil, domain.ErrUpstreamTimeout)
+
+	for _, url := range []string{"ok", "cached", "missing", "timeout"} {
+		_, _ = d.Download(context.Background(), url, nil, dto.OriginCache{})
+	}
+
+	require.Equal(t, 1.0, testutil.ToFloat64(m.downloads.WithLabelValues("200")))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.downloads.WithLabelValues("304")))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.downloads.WithLabelValues("404")))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.downloads.WithLabelValues("error")))
+	require.Equal(t, 100.0, testutil.ToFloat64(m.downloadBytes))
+	require.Equal(t, 1, testutil.CollectAndCount(m.downloadDuration))
+}
+
+func TestImageProcessor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMetrics(prometheus.NewRegistry())
+	next := mocks.NewMockImageProcessor(ctrl)
+	p := NewImageProcessor(next, m)
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+
+	next.EXPECT().Decode(gomock.Any(), true).Return(img, nil)
+	next.EXPECT().Transform(img, gomock.Any()).Return(img, nil)
+	next.EXPECT().Encode(img, dto.FormatJPEG).Return(&dto.Preview{}, nil)
+
+	_, _ = p.Decode(&dto.SourceImage{}, true)
+	_, _ = p.Transform(img, dto.Transformation{})
+	_, _ = p.Encode(img, dto.FormatJPEG)
+
+	require.Equal(t, 3, testutil.CollectAndCount(m.processingDuration))
+}
+
+func TestCacheCollector(t *testing.T) {
+	expected := `
+# HELP previewer_cache_bytes Total size of cached previews in bytes.
+# TYPE previewer_cache_bytes gauge
+previewer_cache_bytes 1024
+# HELP previewer_cache_entries Number of previews in the cache.
+# TYPE previewer_cache_entries gauge
+previewer_cache_entries 3
+# HELP previewer_cache_evictions_total Previews evicted from the cache.
+# TYPE previewer_cache_evictions_total counter
+previewer_cache_evictions_total 7
+`
+
+	require.Nil(t, testutil.CollectAndCompare(NewCacheCollector(fakeStats{}), strings.NewReader(expected)))
+}
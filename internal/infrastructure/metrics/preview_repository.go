@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"context"
+	"image-previewer/internal/application/handlers"
+	"image-previewer/internal/domain"
+	"image-previewer/internal/domain/dto"
+)
+
+// PreviewRepository counts cache hits and misses of the wrapped repository.
+type PreviewRepository struct {
+	next    domain.PreviewRepository
+	metrics *Metrics
+}
+
+func (r *PreviewRepository) FindOne(ctx context.Context, id domain.ImageID) (*dto.PreviewStream, error) {
+	stream, err := r.next.FindOne(ctx, id)
+
+	switch {
+	case err == nil:
+		r.metrics.cacheLookups.WithLabelValues("hit").Inc()
+	case err == handlers.ErrNotFound:
+		r.metrics.cacheLookups.WithLabelValues("miss").Inc()
+	}
+
+	return stream, err
+}
+
+func (r *PreviewRepository) Add(ctx context.Context, id domain.ImageID, preview *dto.Preview) (bool, error) {
+	return r.next.Add(ctx, id, preview)
+}
+
+func (r *PreviewRepository) Refresh(ctx context.Context, id domain.ImageID, origin dto.OriginCache) (*dto.Preview, error) {
+	return r.next.Refresh(ctx, id, origin)
+}
+
+func NewPreviewRepository(next domain.PreviewRepository, m *Metrics) *PreviewRepository {
+	return &PreviewRepository{
+		next:    next,
+		metrics: m,
+	}
+}
@@ -0,0 +1,46 @@
+package processor
+
+import (
+	"image"
+	"image-previewer/internal/domain/dto"
+	"image/color"
+
+	"github.com/disintegration/imaging"
+)
+
+func applyFilters(img image.Image, filters dto.Filters) image.Image {
+	for _, f := range filters {
+		switch f.Type {
+		case dto.FilterBlur:
+			img = imaging.Blur(img, f.Amount)
+		case dto.FilterSharpen:
+			img = imaging.Sharpen(img, f.Amount)
+		case dto.FilterGrayscale:
+			img = imaging.Grayscale(img)
+		case dto.FilterRotate:
+			img = rotate(img, f.Amount)
+		case dto.FilterFlipHorizontal:
+			img = imaging.FlipH(img)
+		case dto.FilterFlipVertical:
+			img = imaging.FlipV(img)
+		}
+	}
+
+	return img
+}
+
+// rotate turns the image clockwise, imaging rotates counter-clockwise.
+func rotate(img image.Image, angle float64) image.Image {
+	switch angle {
+	case 0:
+		return img
+	case 90:
+		return imaging.Rotate270(img)
+	case 180:
+		return imaging.Rotate180(img)
+	case 270:
+		return imaging.Rotate90(img)
+	default:
+		return imaging.Rotate(img, 360-angle, color.Transparent)
+	}
+}
@@ -0,0 +1,58 @@
+package processor
+
+import (
+	"image"
+	"image-previewer/internal/domain/dto"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyFilters(t *testing.T) {
+	red := color.NRGBA{R: 255, A: 255}
+
+	src := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	src.Set(0, 0, red)
+
+	t.Run("rotate is clockwise", func(t *testing.T) {
+		img := applyFilters(src, dto.Filters{{Type: dto.FilterRotate, Amount: 90}})
+
+		require.Equal(t, image.Rect(0, 0, 20, 40), img.Bounds())
+		require.Equal(t, red, img.At(19, 0))
+	})
+
+	t.Run("flips", func(t *testing.T) {
+		img := applyFilters(src, dto.Filters{{Type: dto.FilterFlipHorizontal}})
+		require.Equal(t, red, img.At(39, 0))
+
+		img = applyFilters(src, dto.Filters{{Type: dto.FilterFlipVertical}})
+		require.Equal(t, red, img.At(0, 19))
+	})
+
+	t.Run("grayscale", func(t *testing.T) {
+		img := applyFilters(src, dto.Filters{{Type: dto.FilterGrayscale}})
+
+		r, g, b, _ := img.At(0, 0).RGBA()
+		require.Equal(t, r, g)
+		require.Equal(t, g, b)
+	})
+
+	t.Run("chain keeps order", func(t *testing.T) {
+		img := applyFilters(src, dto.Filters{
+			{Type: dto.FilterFlipHorizontal},
+			{Type: dto.FilterRotate, Amount: 270},
+		})
+
+		require.Equal(t, red, img.At(0, 0))
+	})
+
+	t.Run("blur and sharpen keep size", func(t *testing.T) {
+		img := applyFilters(src, dto.Filters{
+			{Type: dto.FilterBlur, Amount: 2},
+			{Type: dto.FilterSharpen, Amount: 1},
+		})
+
+		require.Equal(t, src.Bounds(), img.Bounds())
+	})
+}
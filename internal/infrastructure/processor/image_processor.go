@@ -0,0 +1,133 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image-previewer/internal/domain"
+	"image-previewer/internal/domain/dto"
+	"image-previewer/internal/infrastructure/codec"
+
+	"github.com/disintegration/imaging"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrUnsupportedFormat = domain.NewError(domain.ErrUnsupportedMedia, "image format is not supported")
+	ErrInvalidImage      = domain.NewError(domain.ErrUnsupportedMedia, "image should have correct structure")
+	ErrImageTooLarge     = domain.NewError(domain.ErrUnprocessable, "image dimensions exceed the pixel limit")
+)
+
+var filters = map[string]imaging.ResampleFilter{
+	"nearest":    imaging.NearestNeighbor,
+	"box":        imaging.Box,
+	"linear":     imaging.Linear,
+	"catmullrom": imaging.CatmullRom,
+	"lanczos":    imaging.Lanczos,
+}
+
+type ImageProcessor struct {
+	codecs *codec.Registry
+	filter imaging.ResampleFilter
+	// maxPixels caps the decoded area, 0 means unlimited
+	maxPixels int
+}
+
+func (p *ImageProcessor) Decode(src *dto.SourceImage, autoOrient bool) (image.Image, error) {
+	if err := p.checkDimensions(src); err != nil {
+		return nil, err
+	}
+
+	img, format, err := p.codecs.Decode(bytes.NewReader(src.Content), src.ContentType)
+	if err == codec.ErrUnsupportedFormat {
+		return nil, ErrUnsupportedFormat
+	}
+
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	zap.S().Debugf("decoded %s image %d x %d", format, img.Bounds().Dx(), img.Bounds().Dy())
+
+	if autoOrient && format == dto.FormatJPEG {
+		img = codec.Orient(img, codec.JPEGOrientation(src.Content))
+	}
+
+	return img, nil
+}
+
+// checkDimensions reads only the header, a small file may still declare a huge canvas.
+func (p *ImageProcessor) checkDimensions(src *dto.SourceImage) error {
+	if p.maxPixels <= 0 {
+		return nil
+	}
+
+	cfg, _, err := p.codecs.DecodeConfig(bytes.NewReader(src.Content), src.ContentType)
+	if err == codec.ErrUnsupportedFormat {
+		return ErrUnsupportedFormat
+	}
+
+	if err != nil {
+		return ErrInvalidImage
+	}
+
+	if int64(cfg.Width)*int64(cfg.Height) > int64(p.maxPixels) {
+		return ErrImageTooLarge
+	}
+
+	return nil
+}
+
+func (p *ImageProcessor) Transform(img image.Image, t dto.Transformation) (image.Image, error) {
+	zap.S().Debugf("applying %s %d x %d %s", t.Mode, t.Dimensions.Width, t.Dimensions.Height, t.Filters)
+
+	return applyFilters(transform(img, t, p.filter), t.Filters), nil
+}
+
+func (p *ImageProcessor) Encode(img image.Image, format dto.ImageFormat) (*dto.Preview, error) {
+	if format == dto.FormatAuto {
+		format = autoFormat(img)
+	}
+
+	encoder, err := p.codecs.Encoder(format)
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+
+	buf := new(bytes.Buffer)
+
+	if err := encoder.Encode(buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode %s image: %w", format, err)
+	}
+
+	return &dto.Preview{
+		Content:     buf.Bytes(),
+		ContentType: encoder.ContentType(),
+	}, nil
+}
+
+// autoFormat keeps transparency where there is any, images that can't tell are assumed opaque.
+func autoFormat(img image.Image) dto.ImageFormat {
+	if o, ok := img.(interface{ Opaque() bool }); ok && !o.Opaque() {
+		return dto.FormatPNG
+	}
+
+	return dto.FormatJPEG
+}
+
+func FilterByName(name string) (imaging.ResampleFilter, error) {
+	filter, ok := filters[name]
+	if !ok {
+		return imaging.ResampleFilter{}, fmt.Errorf("unknown resample filter %q", name)
+	}
+
+	return filter, nil
+}
+
+func NewImageProcessor(codecs *codec.Registry, filter imaging.ResampleFilter, maxPixels int) *ImageProcessor {
+	return &ImageProcessor{
+		codecs:    codecs,
+		filter:    filter,
+		maxPixels: maxPixels,
+	}
+}
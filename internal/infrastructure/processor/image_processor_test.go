@@ -0,0 +1,153 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image-previewer/internal/domain/dto"
+	"image-previewer/internal/infrastructure/codec"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io/ioutil"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageProcessor_Decode(t *testing.T) {
+	p := NewImageProcessor(codec.NewDefaultRegistry(), imaging.Lanczos, 0)
+
+	t.Run("unsupported image format", func(t *testing.T) {
+		img, err := p.Decode(&dto.SourceImage{
+			Content:     []byte("<html></html>"),
+			ContentType: "text/html",
+		}, true)
+
+		require.Nil(t, img)
+		require.Equal(t, ErrUnsupportedFormat, err)
+	})
+
+	t.Run("corrupted image", func(t *testing.T) {
+		img, err := p.Decode(&dto.SourceImage{
+			Content: []byte("\xff\xd8broken"),
+		}, true)
+
+		require.Nil(t, img)
+		require.Equal(t, ErrInvalidImage, err)
+	})
+
+	t.Run("png announced as jpeg", func(t *testing.T) {
+		content, _ := ioutil.ReadFile("../../../tests/data/_gopher_original_1024x504.png")
+
+		img, err := p.Decode(&dto.SourceImage{
+			Content:     content,
+			ContentType: "image/jpeg",
+		}, true)
+
+		require.Nil(t, err)
+		require.Equal(t, 1024, img.Bounds().Dx())
+		require.Equal(t, 504, img.Bounds().Dy())
+	})
+
+	t.Run("pixel limit", func(t *testing.T) {
+		content, _ := ioutil.ReadFile("../../../tests/data/_gopher_original_1024x504.png")
+		src := &dto.SourceImage{Content: content}
+
+		img, err := NewImageProcessor(codec.NewDefaultRegistry(), imaging.Lanczos, 1024*504-1).Decode(src, true)
+
+		require.Nil(t, img)
+		require.Equal(t, ErrImageTooLarge, err)
+
+		img, err = NewImageProcessor(codec.NewDefaultRegistry(), imaging.Lanczos, 1024*504).Decode(src, true)
+
+		require.Nil(t, err)
+		require.NotNil(t, img)
+	})
+}
+
+func TestImageProcessor_DecodeOrientation(t *testing.T) {
+	p := NewImageProcessor(codec.NewDefaultRegistry(), imaging.Lanczos, 0)
+
+	for orientation := 1; orientation <= 8; orientation++ {
+		content, _ := ioutil.ReadFile(fmt.Sprintf("../../../tests/data/orientation/orientation_%d.jpg", orientation))
+
+		img, err := p.Decode(&dto.SourceImage{Content: content}, true)
+
+		require.Nil(t, err)
+		require.Equal(t, image.Rect(0, 0, 64, 32), img.Bounds(), orientation)
+	}
+
+	t.Run("auto orientation disabled", func(t *testing.T) {
+		content, _ := ioutil.ReadFile("../../../tests/data/orientation/orientation_6.jpg")
+
+		img, err := p.Decode(&dto.SourceImage{Content: content}, false)
+
+		require.Nil(t, err)
+		require.Equal(t, image.Rect(0, 0, 32, 64), img.Bounds())
+	})
+}
+
+func TestImageProcessor_Transform(t *testing.T) {
+	p := NewImageProcessor(codec.NewDefaultRegistry(), imaging.Box, 0)
+
+	img, err := p.Transform(image.NewNRGBA(image.Rect(0, 0, 400, 200)), dto.Transformation{
+		Mode: dto.ModeFill,
+		Dimensions: dto.ImageDimensions{
+			Width:  200,
+			Height: 200,
+		},
+	})
+
+	require.Nil(t, err)
+	require.Equal(t, image.Rect(0, 0, 200, 200), img.Bounds())
+}
+
+func TestImageProcessor_Encode(t *testing.T) {
+	p := NewImageProcessor(codec.NewDefaultRegistry(), imaging.Lanczos, 0)
+	src := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+
+	t.Run("supported format", func(t *testing.T) {
+		preview, err := p.Encode(src, dto.FormatPNG)
+
+		require.Nil(t, err)
+		require.Equal(t, "image/png", preview.ContentType)
+
+		img, err := png.Decode(bytes.NewReader(preview.Content))
+		require.Nil(t, err)
+		require.Equal(t, src.Bounds(), img.Bounds())
+	})
+
+	t.Run("auto format", func(t *testing.T) {
+		preview, err := p.Encode(src, dto.FormatAuto)
+
+		require.Nil(t, err)
+		require.Equal(t, "image/png", preview.ContentType)
+
+		opaque := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+		draw.Draw(opaque, opaque.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+		preview, err = p.Encode(opaque, dto.FormatAuto)
+
+		require.Nil(t, err)
+		require.Equal(t, "image/jpeg", preview.ContentType)
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		preview, err := p.Encode(src, dto.FormatWebP)
+
+		require.Nil(t, preview)
+		require.Equal(t, ErrUnsupportedFormat, err)
+	})
+}
+
+func TestFilterByName(t *testing.T) {
+	filter, err := FilterByName("box")
+
+	require.Nil(t, err)
+	require.Equal(t, imaging.Box.Support, filter.Support)
+
+	_, err = FilterByName("bicubic")
+	require.NotNil(t, err)
+}
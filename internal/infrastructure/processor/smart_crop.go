@@ -0,0 +1,118 @@
+package processor
+
+import (
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// analysisSize bounds the longest side of the copy used to score crop windows.
+const analysisSize = 128
+
+// smartCropRect finds the width x height window of img with the highest edge density.
+// Scoring runs on a downscaled grayscale copy, so the cost does not depend on the source size.
+func smartCropRect(img image.Image, width, height int) image.Rectangle {
+	bounds := img.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+
+	width, height = minInt(width, srcWidth), minInt(height, srcHeight)
+
+	if width == srcWidth && height == srcHeight {
+		return bounds
+	}
+
+	factor := math.Max(1, float64(maxInt(srcWidth, srcHeight))/analysisSize)
+	smallWidth := maxInt(1, int(math.Round(float64(srcWidth)/factor)))
+	smallHeight := maxInt(1, int(math.Round(float64(srcHeight)/factor)))
+	small := imaging.Resize(imaging.Grayscale(img), smallWidth, smallHeight, imaging.Box)
+
+	integral := edgeIntegral(small)
+
+	windowWidth := clampInt(int(math.Round(float64(width)/factor)), 1, smallWidth)
+	windowHeight := clampInt(int(math.Round(float64(height)/factor)), 1, smallHeight)
+
+	// a uniform image has no preferred window, so the centered one wins ties
+	bestX, bestY := (smallWidth-windowWidth)/2, (smallHeight-windowHeight)/2
+	bestScore := windowSum(integral, bestX, bestY, windowWidth, windowHeight)
+
+	for y := 0; y+windowHeight <= smallHeight; y++ {
+		for x := 0; x+windowWidth <= smallWidth; x++ {
+			if score := windowSum(integral, x, y, windowWidth, windowHeight); score > bestScore {
+				bestX, bestY, bestScore = x, y, score
+			}
+		}
+	}
+
+	x := clampInt(int(math.Round(float64(bestX)*factor)), 0, srcWidth-width)
+	y := clampInt(int(math.Round(float64(bestY)*factor)), 0, srcHeight-height)
+
+	return image.Rect(x, y, x+width, y+height).Add(bounds.Min)
+}
+
+// edgeIntegral builds a summed-area table of the luminance gradient magnitude.
+func edgeIntegral(img *image.NRGBA) [][]int64 {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+
+	integral := make([][]int64, height+1)
+	for y := range integral {
+		integral[y] = make([]int64, width+1)
+	}
+
+	lum := func(x, y int) int64 {
+		return int64(img.Pix[y*img.Stride+x*4])
+	}
+
+	for y := 0; y < height; y++ {
+		var rowSum int64
+
+		for x := 0; x < width; x++ {
+			var energy int64
+
+			if x+1 < width {
+				energy += absInt64(lum(x+1, y) - lum(x, y))
+			}
+
+			if y+1 < height {
+				energy += absInt64(lum(x, y+1) - lum(x, y))
+			}
+
+			rowSum += energy
+			integral[y+1][x+1] = integral[y][x+1] + rowSum
+		}
+	}
+
+	return integral
+}
+
+func windowSum(integral [][]int64, x, y, width, height int) int64 {
+	return integral[y+height][x+width] - integral[y][x+width] - integral[y+height][x] + integral[y][x]
+}
+
+func absInt64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+
+	return v
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+func clampInt(v, min, max int) int {
+	return maxInt(min, minInt(v, max))
+}
@@ -0,0 +1,44 @@
+package processor
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSmartCropRect(t *testing.T) {
+	t.Run("uniform image is cropped at the center", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 400, 200))
+
+		require.Equal(t, image.Rect(150, 0, 250, 200), smartCropRect(img, 100, 200))
+	})
+
+	t.Run("window follows the detailed area", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 1000, 500))
+		draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+		// checkerboard subject in the top right corner
+		for y := 20; y < 220; y++ {
+			for x := 750; x < 950; x++ {
+				if (x/10+y/10)%2 == 0 {
+					img.Set(x, y, color.Black)
+				}
+			}
+		}
+
+		rect := smartCropRect(img, 250, 250)
+
+		require.Equal(t, 250, rect.Dx())
+		require.Equal(t, 250, rect.Dy())
+		require.True(t, image.Rect(750, 20, 950, 220).In(rect), rect)
+	})
+
+	t.Run("window larger than image", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+
+		require.Equal(t, img.Bounds(), smartCropRect(img, 100, 100))
+	})
+}
@@ -0,0 +1,92 @@
+package processor
+
+import (
+	"image"
+	"image-previewer/internal/domain/dto"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+var anchors = map[dto.Gravity]imaging.Anchor{
+	dto.GravityCenter:    imaging.Center,
+	dto.GravityNorth:     imaging.Top,
+	dto.GravitySouth:     imaging.Bottom,
+	dto.GravityEast:      imaging.Right,
+	dto.GravityWest:      imaging.Left,
+	dto.GravityNorthEast: imaging.TopRight,
+	dto.GravityNorthWest: imaging.TopLeft,
+	dto.GravitySouthEast: imaging.BottomRight,
+	dto.GravitySouthWest: imaging.BottomLeft,
+}
+
+func transform(img image.Image, t dto.Transformation, filter imaging.ResampleFilter) image.Image {
+	width, height := t.Dimensions.Width, t.Dimensions.Height
+
+	switch t.Mode {
+	case dto.ModeFit:
+		return imaging.Fit(img, width, height, filter)
+	case dto.ModeResize:
+		return imaging.Resize(img, width, height, filter)
+	case dto.ModeCrop:
+		return crop(img, t)
+	case dto.ModeThumbnail:
+		return letterbox(img, t, filter)
+	default:
+		return fill(img, t, filter)
+	}
+}
+
+func fill(img image.Image, t dto.Transformation, filter imaging.ResampleFilter) image.Image {
+	width, height := t.Dimensions.Width, t.Dimensions.Height
+
+	if t.Gravity != dto.GravitySmart {
+		return imaging.Fill(img, width, height, anchor(t.Gravity), filter)
+	}
+
+	srcWidth, srcHeight := img.Bounds().Dx(), img.Bounds().Dy()
+	scale := math.Max(float64(width)/float64(srcWidth), float64(height)/float64(srcHeight))
+
+	window := smartCropRect(
+		img,
+		clampInt(int(math.Round(float64(width)/scale)), 1, srcWidth),
+		clampInt(int(math.Round(float64(height)/scale)), 1, srcHeight),
+	)
+
+	return imaging.Resize(imaging.Crop(img, window), width, height, filter)
+}
+
+func crop(img image.Image, t dto.Transformation) image.Image {
+	width, height := t.Dimensions.Width, t.Dimensions.Height
+
+	if t.Gravity != dto.GravitySmart {
+		return imaging.CropAnchor(img, width, height, anchor(t.Gravity))
+	}
+
+	return imaging.Crop(img, smartCropRect(img, width, height))
+}
+
+// letterbox scales the image (up or down) to fit into the box and centers it on the background.
+func letterbox(img image.Image, t dto.Transformation, filter imaging.ResampleFilter) image.Image {
+	width, height := t.Dimensions.Width, t.Dimensions.Height
+	srcWidth, srcHeight := img.Bounds().Dx(), img.Bounds().Dy()
+
+	ratio := math.Min(float64(width)/float64(srcWidth), float64(height)/float64(srcHeight))
+
+	fitted := imaging.Resize(
+		img,
+		int(math.Max(1, math.Round(float64(srcWidth)*ratio))),
+		int(math.Max(1, math.Round(float64(srcHeight)*ratio))),
+		filter,
+	)
+
+	return imaging.PasteCenter(imaging.New(width, height, t.Background), fitted)
+}
+
+func anchor(g dto.Gravity) imaging.Anchor {
+	if a, ok := anchors[g]; ok {
+		return a
+	}
+
+	return imaging.Center
+}
@@ -0,0 +1,107 @@
+package processor
+
+import (
+	"image"
+	"image-previewer/internal/domain/dto"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransform(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 400, 200))
+
+	cases := []struct {
+		mode   dto.ResizeMode
+		width  int
+		height int
+	}{
+		{dto.ModeFill, 100, 100},
+		{dto.ModeFit, 100, 50},
+		{dto.ModeResize, 100, 100},
+		{dto.ModeCrop, 100, 100},
+		{dto.ModeThumbnail, 100, 100},
+	}
+
+	for _, c := range cases {
+		img := transform(src, dto.Transformation{
+			Mode: c.mode,
+			Dimensions: dto.ImageDimensions{
+				Width:  100,
+				Height: 100,
+			},
+		}, imaging.Lanczos)
+
+		require.Equal(t, c.width, img.Bounds().Dx(), c.mode)
+		require.Equal(t, c.height, img.Bounds().Dy(), c.mode)
+	}
+
+	t.Run("fit does not upscale", func(t *testing.T) {
+		img := transform(src, dto.Transformation{
+			Mode: dto.ModeFit,
+			Dimensions: dto.ImageDimensions{
+				Width:  800,
+				Height: 800,
+			},
+		}, imaging.Lanczos)
+
+		require.Equal(t, src.Bounds(), img.Bounds())
+	})
+
+	t.Run("gravity anchors the crop window", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 400, 200))
+		red := color.NRGBA{R: 255, A: 255}
+		img.Set(0, 0, red)
+
+		north := transform(img, dto.Transformation{
+			Mode:    dto.ModeCrop,
+			Gravity: dto.GravityNorthWest,
+			Dimensions: dto.ImageDimensions{
+				Width:  10,
+				Height: 10,
+			},
+		}, imaging.Lanczos)
+		center := transform(img, dto.Transformation{
+			Mode: dto.ModeCrop,
+			Dimensions: dto.ImageDimensions{
+				Width:  10,
+				Height: 10,
+			},
+		}, imaging.Lanczos)
+
+		require.Equal(t, red, north.At(0, 0))
+		require.NotEqual(t, red, center.At(0, 0))
+	})
+
+	t.Run("smart fill keeps requested size", func(t *testing.T) {
+		img := transform(src, dto.Transformation{
+			Mode:    dto.ModeFill,
+			Gravity: dto.GravitySmart,
+			Dimensions: dto.ImageDimensions{
+				Width:  120,
+				Height: 80,
+			},
+		}, imaging.Lanczos)
+
+		require.Equal(t, 120, img.Bounds().Dx())
+		require.Equal(t, 80, img.Bounds().Dy())
+	})
+
+	t.Run("thumbnail pads with background", func(t *testing.T) {
+		red := color.NRGBA{R: 255, A: 255}
+
+		img := transform(src, dto.Transformation{
+			Mode: dto.ModeThumbnail,
+			Dimensions: dto.ImageDimensions{
+				Width:  100,
+				Height: 100,
+			},
+			Background: red,
+		}, imaging.Lanczos)
+
+		require.Equal(t, red, img.At(50, 5))
+		require.Equal(t, color.NRGBA{}, img.At(50, 50))
+	})
+}
@@ -2,27 +2,46 @@ package repository
 
 import (
 	"container/list"
+	"context"
+	"errors"
 	"fmt"
-	"image"
 	"image-previewer/internal/application/handlers"
 	"image-previewer/internal/domain"
-	"image/jpeg"
+	"image-previewer/internal/domain/dto"
+	"io/ioutil"
 	"os"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"go.uber.org/zap"
 )
 
+type entry struct {
+	id   domain.ImageID
+	size int64
+}
+
+// FileStorage is an LRU cache of encoded previews on disk, bounded by the number of entries
+// and by their total size in bytes. A zero limit is not enforced.
 type FileStorage struct {
 	cacheDir string
 	capacity int
-	cache    list.List
-	items    map[domain.ImageID]*list.Element
-	mux      sync.Mutex
+	maxBytes int64
+	size     int64
+	// evictions counts previews removed to respect the limits or because they were unreadable
+	evictions uint64
+	cache     list.List
+	items     map[domain.ImageID]*list.Element
+	mux       sync.Mutex
 }
 
-func (r *FileStorage) FindOne(id domain.ImageID) (image.Image, error) {
+func (r *FileStorage) FindOne(ctx context.Context, id domain.ImageID) (*dto.PreviewStream, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	r.mux.Lock()
 	defer r.mux.Unlock()
 
@@ -33,107 +52,253 @@ func (r *FileStorage) FindOne(id domain.ImageID) (image.Image, error) {
 	}
 
 	if err := r.touchPreview(id); err != nil {
-		return nil, err
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+
+		// removed behind our back
+		return nil, r.discard(element, err)
 	}
 
 	r.cache.MoveToFront(element)
 
-	img, err := r.loadPreview(id)
+	preview, err := r.loadPreview(id)
 	if err != nil {
-		return nil, err
+		// e.g. left by an older build
+		return nil, r.discard(element, err)
+	}
+
+	return preview, nil
+}
+
+// discard evicts a preview that can't be served, so it is rendered again rather than failing every request.
+func (r *FileStorage) discard(element *list.Element, reason error) error {
+	zap.S().Warnf("evicting unusable preview: %s", reason)
+
+	if err := r.evict(element); err != nil {
+		return err
 	}
 
-	return img, nil
+	return handlers.ErrNotFound
 }
 
-func (r *FileStorage) Add(id domain.ImageID, img image.Image) (bool, error) {
+func (r *FileStorage) Add(ctx context.Context, id domain.ImageID, preview *dto.Preview) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
 	r.mux.Lock()
 	defer r.mux.Unlock()
 
+	size, err := r.savePreview(id, preview)
+	if err != nil {
+		return false, err
+	}
+
 	element, exists := r.items[id]
 
 	if exists {
-		zap.S().Debugf("item exist in cache, moving to front")
-
-		if err := r.touchPreview(id); err != nil {
-			return true, err
-		}
+		zap.S().Debugf("item exist in cache, replacing and moving to front")
 
+		r.resize(element, size)
 		r.cache.MoveToFront(element)
-		element.Value = id
+	} else {
+		zap.S().Debugf("new item, saving and pushing to front")
 
-		return true, nil
+		r.items[id] = r.cache.PushFront(&entry{id: id, size: size})
+		r.size += size
 	}
 
-	if r.cache.Len() == r.capacity {
-		zap.S().Debugf("cache capacity limit exceed, removing last item")
+	return exists, r.shrink()
+}
+
+func (r *FileStorage) Refresh(ctx context.Context, id domain.ImageID, origin dto.OriginCache) (*dto.Preview, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
-		lastItem := r.cache.Back()
-		lastItemID := lastItem.Value.(domain.ImageID)
+	r.mux.Lock()
+	defer r.mux.Unlock()
 
-		if err := r.removePreview(lastItemID); err != nil {
-			return false, err
-		}
+	element, exists := r.items[id]
 
-		r.cache.Remove(lastItem)
-		delete(r.items, lastItemID)
+	if !exists {
+		return nil, handlers.ErrNotFound
+	}
+
+	stream, err := r.loadPreview(id)
+	if err != nil {
+		return nil, err
 	}
 
-	zap.S().Debugf("new item, saving and pushing to front")
+	content, err := ioutil.ReadAll(stream.Content)
+	stream.Content.Close()
 
-	if err := r.savePreview(id, img); err != nil {
-		return false, err
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %s", r.pathByID(id), err)
 	}
 
-	element = r.cache.PushFront(id)
-	r.items[id] = element
+	preview := &dto.Preview{
+		Content:     content,
+		ContentType: stream.ContentType,
+		ETag:        stream.ETag,
+		ModTime:     stream.ModTime,
+		Origin:      origin,
+	}
 
-	return false, nil
+	size, err := r.savePreview(id, preview)
+	if err != nil {
+		return nil, err
+	}
+
+	r.resize(element, size)
+	r.cache.MoveToFront(element)
+
+	return preview, r.shrink()
 }
 
-func (r *FileStorage) Len() int {
-	return r.cache.Len()
+func (r *FileStorage) resize(element *list.Element, size int64) {
+	e := element.Value.(*entry)
+	r.size += size - e.size
+	e.size = size
 }
 
-func (r *FileStorage) savePreview(id domain.ImageID, img image.Image) error {
-	path := r.pathByID(id)
+// shrink evicts least recently used previews until the limits hold, a preview bigger
+// than the whole budget goes right away as well.
+func (r *FileStorage) shrink() error {
+	for r.overLimit() {
+		zap.S().Debugf("cache capacity limit exceed, removing last item")
 
-	out, err := os.Create(path)
+		if err := r.evict(r.cache.Back()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *FileStorage) overLimit() bool {
+	return (r.capacity > 0 && r.cache.Len() > r.capacity) || (r.maxBytes > 0 && r.size > r.maxBytes)
+}
+
+func (r *FileStorage) evict(element *list.Element) error {
+	e := element.Value.(*entry)
+
+	if err := r.removePreview(e.id); err != nil {
+		return err
+	}
+
+	r.cache.Remove(element)
+	delete(r.items, e.id)
+	r.size -= e.size
+	r.evictions++
+
+	return nil
+}
+
+// Restore rebuilds the index from previews left by a previous run, most recently used first
+// as touchPreview keeps mtimes current, and removes whatever doesn't fit into the capacity along with
+// temp files of writes interrupted by a crash.
+func (r *FileStorage) Restore() error {
+	files, err := ioutil.ReadDir(r.cacheDir)
 	if err != nil {
-		return fmt.Errorf("failed to create file %s: %s", path, err)
+		return fmt.Errorf("failed to read cache dir %s: %s", r.cacheDir, err)
 	}
 
-	defer out.Close()
+	previews := files[:0]
+
+	for _, file := range files {
+		if file.Mode().IsRegular() && isTempFile(file.Name()) {
+			if err := os.Remove(r.cacheDir + file.Name()); err != nil {
+				return fmt.Errorf("failed to remove leftover file %s: %s", file.Name(), err)
+			}
+
+			continue
+		}
+
+		// dotfiles such as .gitkeep are not previews
+		if file.Mode().IsRegular() && !strings.HasPrefix(file.Name(), ".") {
+			previews = append(previews, file)
+		}
+	}
+
+	sort.SliceStable(previews, func(i, j int) bool {
+		return previews[i].ModTime().After(previews[j].ModTime())
+	})
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.cache.Init()
+	r.items = make(map[domain.ImageID]*list.Element)
+	r.size = 0
+
+	for _, file := range previews {
+		id := domain.ImageID(file.Name())
 
-	if err := jpeg.Encode(out, img, nil); err != nil {
-		return fmt.Errorf("failed to encode image %s: %s", path, err)
+		r.items[id] = r.cache.PushBack(&entry{id: id, size: file.Size()})
+		r.size += file.Size()
 	}
 
+	if err := r.shrink(); err != nil {
+		return err
+	}
+
+	zap.S().Infof("restored %d cached previews, evicted %d", r.cache.Len(), len(previews)-r.cache.Len())
+
 	return nil
 }
 
-func (r *FileStorage) loadPreview(id domain.ImageID) (image.Image, error) {
+func (r *FileStorage) Len() int {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	return r.cache.Len()
+}
+
+// Size is the total size of cached previews in bytes.
+func (r *FileStorage) Size() int64 {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	return r.size
+}
+
+// Evictions is the number of previews evicted since start, restore included.
+func (r *FileStorage) Evictions() uint64 {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	return r.evictions
+}
+
+func (r *FileStorage) savePreview(id domain.ImageID, preview *dto.Preview) (int64, error) {
 	path := r.pathByID(id)
 
-	file, err := os.Open(path)
+	size, err := writePreviewFile(path, preview)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file %s: %s", path, err)
+		return 0, fmt.Errorf("failed to create file %s: %s", path, err)
 	}
 
-	defer file.Close()
+	return size, nil
+}
+
+func (r *FileStorage) loadPreview(id domain.ImageID) (*dto.PreviewStream, error) {
+	path := r.pathByID(id)
 
-	img, err := jpeg.Decode(file)
+	preview, err := openPreviewFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode file %s: %s", path, err)
+		return nil, fmt.Errorf("failed to open file %s: %s", path, err)
 	}
 
-	return img, nil
+	return preview, nil
 }
 
 func (r *FileStorage) removePreview(id domain.ImageID) error {
 	path := r.pathByID(id)
 
-	if err := os.Remove(path); err != nil {
+	// already gone is as good as removed
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to remove file %s: %s", path, err)
 	}
 
@@ -144,7 +309,7 @@ func (r *FileStorage) touchPreview(id domain.ImageID) error {
 	path := r.pathByID(id)
 
 	if err := os.Chtimes(path, time.Now(), time.Now()); err != nil {
-		return fmt.Errorf("failed to touch file %s: %s", path, err)
+		return fmt.Errorf("failed to touch file %s: %w", path, err)
 	}
 
 	return nil
@@ -154,10 +319,11 @@ func (r *FileStorage) pathByID(id domain.ImageID) string {
 	return r.cacheDir + string(id)
 }
 
-func NewFileStorage(cacheDir string, capacity int) *FileStorage {
+func NewFileStorage(cacheDir string, capacity int, maxBytes int64) *FileStorage {
 	return &FileStorage{
 		cacheDir: cacheDir,
 		capacity: capacity,
+		maxBytes: maxBytes,
 		items:    make(map[domain.ImageID]*list.Element),
 	}
 }
@@ -1,13 +1,14 @@
 package repository
 
 import (
-	"image"
+	"context"
 	"image-previewer/internal/application/handlers"
 	"image-previewer/internal/domain"
-	"image/jpeg"
+	"image-previewer/internal/domain/dto"
 	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -18,17 +19,18 @@ func TestFileStorage_Add(t *testing.T) {
 	t.Run("valid response status", func(t *testing.T) {
 		defer cleanUp(cacheDir)
 
-		s := NewFileStorage(cacheDir, 5)
+		s := NewFileStorage(cacheDir, 5, 0)
 
 		require.Equal(t, 0, s.Len())
 
-		wasInCache, err := s.Add(domain.ImageID("test1.jpg"), fakedImg())
+		wasInCache, err := s.Add(context.Background(), domain.ImageID("test1.jpg"), fakedPreview())
 		require.False(t, wasInCache)
 		require.Nil(t, err)
-		_, err = os.Open(cacheDir + "test1.jpg")
+		stat, err := os.Stat(cacheDir + "test1.jpg")
 		require.Nil(t, err)
+		require.Equal(t, os.FileMode(0644), stat.Mode().Perm())
 
-		wasInCache, err = s.Add(domain.ImageID("test1.jpg"), fakedImg())
+		wasInCache, err = s.Add(context.Background(), domain.ImageID("test1.jpg"), fakedPreview())
 		require.True(t, wasInCache)
 		require.Nil(t, err)
 		_, err = os.Open(cacheDir + "test1.jpg")
@@ -36,7 +38,7 @@ func TestFileStorage_Add(t *testing.T) {
 
 		require.Equal(t, 1, s.Len())
 
-		wasInCache, err = s.Add(domain.ImageID("test2.jpg"), fakedImg())
+		wasInCache, err = s.Add(context.Background(), domain.ImageID("test2.jpg"), fakedPreview())
 		require.False(t, wasInCache)
 		require.Nil(t, err)
 		_, err = os.Open(cacheDir + "test2.jpg")
@@ -48,16 +50,16 @@ func TestFileStorage_Add(t *testing.T) {
 	t.Run("purge logic", func(t *testing.T) {
 		defer cleanUp(cacheDir)
 
-		s := NewFileStorage(cacheDir, 3)
-		_, _ = s.Add(domain.ImageID("test1.jpg"), fakedImg())
+		s := NewFileStorage(cacheDir, 3, 0)
+		_, _ = s.Add(context.Background(), domain.ImageID("test1.jpg"), fakedPreview())
 		require.Equal(t, 1, s.Len())
-		_, _ = s.Add(domain.ImageID("test2.jpg"), fakedImg())
+		_, _ = s.Add(context.Background(), domain.ImageID("test2.jpg"), fakedPreview())
 		require.Equal(t, 2, s.Len())
-		_, _ = s.Add(domain.ImageID("test3.jpg"), fakedImg())
+		_, _ = s.Add(context.Background(), domain.ImageID("test3.jpg"), fakedPreview())
 		require.Equal(t, 3, s.Len())
-		_, _ = s.Add(domain.ImageID("test4.jpg"), fakedImg())
+		_, _ = s.Add(context.Background(), domain.ImageID("test4.jpg"), fakedPreview())
 		require.Equal(t, 3, s.Len())
-		_, _ = s.Add(domain.ImageID("test5.jpg"), fakedImg())
+		_, _ = s.Add(context.Background(), domain.ImageID("test5.jpg"), fakedPreview())
 		require.Equal(t, 3, s.Len())
 
 		_, err := os.Open(cacheDir + "test1.jpg")
@@ -65,38 +67,265 @@ func TestFileStorage_Add(t *testing.T) {
 		_, err = os.Open(cacheDir + "test2.jpg")
 		require.NotNil(t, err)
 	})
+
+	t.Run("purge by size", func(t *testing.T) {
+		defer cleanUp(cacheDir)
+
+		probe := NewFileStorage(cacheDir, 0, 0)
+		_, _ = probe.Add(context.Background(), domain.ImageID("probe.jpg"), fakedPreview())
+		previewSize := probe.Size()
+
+		require.True(t, previewSize > 0)
+		cleanUp(cacheDir)
+
+		s := NewFileStorage(cacheDir, 0, 2*previewSize+previewSize/2)
+
+		for _, id := range []domain.ImageID{"test1.jpg", "test2.jpg", "test3.jpg"} {
+			_, err := s.Add(context.Background(), id, fakedPreview())
+			require.Nil(t, err)
+		}
+
+		require.Equal(t, 2, s.Len())
+		require.Equal(t, 2*previewSize, s.Size())
+		require.Equal(t, uint64(1), s.Evictions())
+
+		_, err := os.Open(cacheDir + "test1.jpg")
+		require.NotNil(t, err)
+
+		tiny := NewFileStorage(cacheDir, 0, previewSize-1)
+		_, err = tiny.Add(context.Background(), domain.ImageID("huge.jpg"), fakedPreview())
+
+		require.Nil(t, err)
+		require.Equal(t, 0, tiny.Len())
+		require.Equal(t, int64(0), tiny.Size())
+	})
 }
 
 func TestFileStorage_FindOne(t *testing.T) {
 	defer cleanUp(cacheDir)
 
 	t.Run("not found case", func(t *testing.T) {
-		s := NewFileStorage(cacheDir, 5)
+		s := NewFileStorage(cacheDir, 5, 0)
 
-		img, err := s.FindOne(domain.ImageID("test500.jpg"))
+		preview, err := s.FindOne(context.Background(), domain.ImageID("test500.jpg"))
 
-		require.Nil(t, img)
+		require.Nil(t, preview)
 		require.Equal(t, err, handlers.ErrNotFound)
 	})
 
+	t.Run("cancelled context", func(t *testing.T) {
+		s := NewFileStorage(cacheDir, 5, 0)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		img, err := s.FindOne(ctx, domain.ImageID("test500.jpg"))
+
+		require.Nil(t, img)
+		require.Equal(t, context.Canceled, err)
+	})
+
 	t.Run("found case", func(t *testing.T) {
-		s := NewFileStorage(cacheDir, 5)
+		s := NewFileStorage(cacheDir, 5, 0)
 		imageID := domain.ImageID("test500.jpg")
-		_, _ = s.Add(imageID, fakedImg())
+		_, _ = s.Add(context.Background(), imageID, fakedPreview())
+
+		preview, err := s.FindOne(context.Background(), imageID)
 
-		img, err := s.FindOne(imageID)
+		require.NotNil(t, preview)
+		require.Nil(t, err)
+		require.Nil(t, preview.Content.Close())
+	})
+
+	t.Run("encoded bytes are returned as stored", func(t *testing.T) {
+		s := NewFileStorage(cacheDir, 5, 0)
+		imageID := domain.ImageID("test500.png")
+		preview := &dto.Preview{
+			Content:     []byte("\x89PNG\r\n\x1a\n{}\n"),
+			ContentType: "image/png",
+			ETag:        `"abc"`,
+			ModTime:     time.Date(2020, 9, 1, 10, 0, 0, 0, time.UTC),
+		}
+		_, _ = s.Add(context.Background(), imageID, preview)
 
-		require.NotNil(t, img)
+		stream, err := s.FindOne(context.Background(), imageID)
 		require.Nil(t, err)
+
+		defer stream.Content.Close()
+
+		content, err := ioutil.ReadAll(stream.Content)
+
+		require.Nil(t, err)
+		require.Equal(t, preview.Content, content)
+		require.Equal(t, "image/png", stream.ContentType)
+		require.Equal(t, int64(len(preview.Content)), stream.Size)
+		require.Equal(t, preview.ETag, stream.ETag)
+		require.True(t, preview.ModTime.Equal(stream.ModTime))
+	})
+
+	t.Run("unreadable file is evicted", func(t *testing.T) {
+		s := NewFileStorage(cacheDir, 5, 0)
+		imageID := domain.ImageID("legacy.jpg")
+		_, _ = s.Add(context.Background(), imageID, fakedPreview())
+		require.Nil(t, ioutil.WriteFile(cacheDir+"legacy.jpg", []byte("\xff\xd8raw jpeg"), 0600))
+
+		stream, err := s.FindOne(context.Background(), imageID)
+
+		require.Nil(t, stream)
+		require.Equal(t, handlers.ErrNotFound, err)
+		require.Equal(t, 0, s.Len())
+
+		_, err = os.Stat(cacheDir + "legacy.jpg")
+		require.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("file removed from outside is evicted", func(t *testing.T) {
+		defer cleanUp(cacheDir)
+
+		s := NewFileStorage(cacheDir, 1, 0)
+		_, _ = s.Add(context.Background(), domain.ImageID("gone.jpg"), fakedPreview())
+		require.Nil(t, os.Remove(cacheDir+"gone.jpg"))
+
+		stream, err := s.FindOne(context.Background(), domain.ImageID("gone.jpg"))
+
+		require.Nil(t, stream)
+		require.Equal(t, handlers.ErrNotFound, err)
+		require.Equal(t, 0, s.Len())
+
+		// eviction of a missing file doesn't fail the add that triggers it either
+		_, _ = s.Add(context.Background(), domain.ImageID("first.jpg"), fakedPreview())
+		require.Nil(t, os.Remove(cacheDir+"first.jpg"))
+
+		_, err = s.Add(context.Background(), domain.ImageID("second.jpg"), fakedPreview())
+
+		require.Nil(t, err)
+		require.Equal(t, 1, s.Len())
 	})
 }
 
-func fakedImg() image.Image {
-	f, _ := os.Open("../../../tests/data/_gopher_500x500.jpg")
-	img, _ := jpeg.Decode(f)
-	f.Close()
+//nolint:funlen
+func TestFileStorage_Restore(t *testing.T) {
+	defer cleanUp(cacheDir)
+
+	s := NewFileStorage(cacheDir, 5, 0)
+	now := time.Now()
+
+	previews := []struct {
+		id    domain.ImageID
+		mtime time.Time
+	}{
+		{"old.jpg", now.Add(-2 * time.Hour)},
+		{"newest.jpg", now},
+		{"middle.jpg", now.Add(-time.Hour)},
+		{"oldest.jpg", now.Add(-3 * time.Hour)},
+	}
+
+	for _, p := range previews {
+		_, _ = s.Add(context.Background(), p.id, fakedPreview())
+		require.Nil(t, os.Chtimes(cacheDir+string(p.id), p.mtime, p.mtime))
+	}
+
+	leftover := cacheDir + ".crashed.jpg.123456"
+	require.Nil(t, ioutil.WriteFile(leftover, []byte("partial"), 0600))
+
+	restored := NewFileStorage(cacheDir, 2, 0)
+
+	require.Nil(t, restored.Restore())
+	require.Equal(t, 2, restored.Len())
+
+	for _, id := range []string{"old.jpg", "oldest.jpg"} {
+		_, err := os.Stat(cacheDir + id)
+		require.True(t, os.IsNotExist(err), id)
+	}
+
+	_, err := os.Stat(cacheDir + ".gitkeep")
+	require.Nil(t, err)
 
-	return img
+	_, err = os.Stat(leftover)
+	require.True(t, os.IsNotExist(err))
+
+	preview, err := restored.FindOne(context.Background(), domain.ImageID("middle.jpg"))
+	require.Nil(t, err)
+	require.Nil(t, preview.Content.Close())
+
+	// middle.jpg was used last, so newest.jpg is the one to go
+	_, _ = restored.Add(context.Background(), domain.ImageID("fresh.jpg"), fakedPreview())
+
+	_, err = restored.FindOne(context.Background(), domain.ImageID("newest.jpg"))
+	require.Equal(t, handlers.ErrNotFound, err)
+
+	preview, err = restored.FindOne(context.Background(), domain.ImageID("middle.jpg"))
+	require.Nil(t, err)
+	require.Nil(t, preview.Content.Close())
+}
+
+//nolint:funlen
+func TestFileStorage_Refresh(t *testing.T) {
+	defer cleanUp(cacheDir)
+
+	s := NewFileStorage(cacheDir, 5, 0)
+	imageID := domain.ImageID("test1.jpg")
+	original := fakedPreview()
+	original.Origin = dto.OriginCache{ETag: `"v1"`, Expires: time.Date(2020, 9, 1, 10, 0, 0, 0, time.UTC)}
+
+	_, _ = s.Add(context.Background(), imageID, original)
+
+	t.Run("missing preview", func(t *testing.T) {
+		preview, err := s.Refresh(context.Background(), domain.ImageID("test500.jpg"), dto.OriginCache{})
+
+		require.Nil(t, preview)
+		require.Equal(t, handlers.ErrNotFound, err)
+	})
+
+	t.Run("origin is replaced, content is kept", func(t *testing.T) {
+		origin := dto.OriginCache{ETag: `"v1"`, Expires: time.Date(2020, 9, 2, 10, 0, 0, 0, time.UTC)}
+
+		preview, err := s.Refresh(context.Background(), imageID, origin)
+
+		require.Nil(t, err)
+		require.Equal(t, original.Content, preview.Content)
+		require.Equal(t, origin, preview.Origin)
+
+		stream, err := s.FindOne(context.Background(), imageID)
+		require.Nil(t, err)
+
+		defer stream.Content.Close()
+
+		content, _ := ioutil.ReadAll(stream.Content)
+		stat, _ := os.Stat(cacheDir + string(imageID))
+
+		require.Equal(t, original.Content, content)
+		require.True(t, origin.Expires.Equal(stream.Origin.Expires))
+		require.Equal(t, stat.Size(), s.Size())
+	})
+
+	t.Run("add replaces content", func(t *testing.T) {
+		replaced, err := s.Add(context.Background(), imageID, &dto.Preview{Content: []byte("v2"), ContentType: "image/jpeg"})
+
+		require.True(t, replaced)
+		require.Nil(t, err)
+
+		stream, err := s.FindOne(context.Background(), imageID)
+		require.Nil(t, err)
+
+		defer stream.Content.Close()
+
+		content, _ := ioutil.ReadAll(stream.Content)
+		stat, _ := os.Stat(cacheDir + string(imageID))
+
+		require.Equal(t, []byte("v2"), content)
+		require.Equal(t, 1, s.Len())
+		require.Equal(t, stat.Size(), s.Size())
+	})
+}
+
+func fakedPreview() *dto.Preview {
+	content, _ := ioutil.ReadFile("../../../tests/data/_gopher_500x500.jpg")
+
+	return &dto.Preview{
+		Content:     content,
+		ContentType: "image/jpeg",
+	}
 }
 
 func cleanUp(cacheDir string) {
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image-previewer/internal/domain/dto"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// previewHeader is the first line of a cache file, the encoded preview follows it verbatim
+// so a hit is served without touching any codec.
+type previewHeader struct {
+	ContentType string `json:"content_type"`
+	ETag        string `json:"etag,omitempty"`
+	// ModTime is kept here since the file mtime moves on every hit to track recency.
+	ModTime time.Time       `json:"mod_time"`
+	Origin  dto.OriginCache `json:"origin"`
+}
+
+// previewFile reads the content past the header and closes the underlying file.
+type previewFile struct {
+	reader *bufio.Reader
+	file   *os.File
+}
+
+func (f *previewFile) Read(p []byte) (int, error) {
+	return f.reader.Read(p)
+}
+
+func (f *previewFile) Close() error {
+	return f.file.Close()
+}
+
+// isTempFile matches the ".<id>.<random>" files writePreviewFile renames into place, one is only left
+// behind by a crash. Other dotfiles such as .gitkeep have no second dot.
+func isTempFile(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.Contains(name[1:], ".")
+}
+
+func writePreviewFile(path string, preview *dto.Preview) (int64, error) {
+	header, err := json.Marshal(previewHeader{
+		ContentType: preview.ContentType,
+		ETag:        preview.ETag,
+		ModTime:     preview.ModTime,
+		Origin:      preview.Origin,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, len(header)+1+len(preview.Content)))
+	buf.Write(header)
+	buf.WriteByte('\n')
+	buf.Write(preview.Content)
+
+	// readers may be streaming the previous version, so it is replaced rather than overwritten
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tmp.Write(buf.Bytes())
+
+	// TempFile creates files readable by the owner only, previews are as public as they were with os.Create
+	if err == nil {
+		err = tmp.Chmod(0644)
+	}
+
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return 0, err
+	}
+
+	return int64(buf.Len()), nil
+}
+
+func openPreviewFile(path string) (*dto.PreviewStream, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+
+		return nil, err
+	}
+
+	reader := bufio.NewReader(file)
+
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		file.Close()
+
+		return nil, fmt.Errorf("missing header: %w", err)
+	}
+
+	var header previewHeader
+
+	if err := json.Unmarshal(line, &header); err != nil {
+		file.Close()
+
+		return nil, fmt.Errorf("broken header: %w", err)
+	}
+
+	return &dto.PreviewStream{
+		Content:     &previewFile{reader: reader, file: file},
+		ContentType: header.ContentType,
+		Size:        stat.Size() - int64(len(line)),
+		ETag:        header.ETag,
+		ModTime:     header.ModTime,
+		Origin:      header.Origin,
+	}, nil
+}
@@ -0,0 +1,38 @@
+package infrastructure
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// URLSigner computes HMAC-SHA256 signatures of preview paths, encoded as unpadded url-safe base64
+// so they fit into a single path segment.
+type URLSigner struct {
+	key []byte
+}
+
+func (s *URLSigner) Sign(path string) string {
+	mac := hmac.New(sha256.New, s.key)
+	_, _ = mac.Write([]byte(path))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *URLSigner) Verify(path, signature string) bool {
+	expected, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, s.key)
+	_, _ = mac.Write([]byte(path))
+
+	return hmac.Equal(expected, mac.Sum(nil))
+}
+
+func NewURLSigner(key string) *URLSigner {
+	return &URLSigner{
+		key: []byte(key),
+	}
+}
@@ -0,0 +1,23 @@
+package infrastructure
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLSigner(t *testing.T) {
+	signer := NewURLSigner("secret")
+	path := "fill/300/200/www.audubon.org/sites/default/files/owl.jpg"
+
+	signature := signer.Sign(path)
+
+	require.Len(t, signature, 43)
+	require.Equal(t, signature, signer.Sign(path))
+	require.True(t, signer.Verify(path, signature))
+
+	require.False(t, signer.Verify("fill/3000/2000/www.audubon.org/sites/default/files/owl.jpg", signature))
+	require.False(t, NewURLSigner("other").Verify(path, signature))
+	require.False(t, signer.Verify(path, "not base64!"))
+	require.False(t, signer.Verify(path, ""))
+}
@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"fmt"
+	"image-previewer/internal/domain/dto"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CacheHeaders configures how browsers and CDNs may keep previews.
+type CacheHeaders struct {
+	// MaxAge of responses, zero makes clients revalidate every time.
+	MaxAge time.Duration
+	// Vary lists request headers previews depend on besides Accept, such responses are private.
+	Vary []string
+}
+
+func (c CacheHeaders) write(header http.Header, preview *dto.PreviewStream) {
+	if preview.ETag != "" {
+		header.Set("ETag", preview.ETag)
+	}
+
+	if !preview.ModTime.IsZero() {
+		header.Set("Last-Modified", preview.ModTime.UTC().Format(http.TimeFormat))
+	}
+
+	scope := "public"
+	if len(c.Vary) > 0 {
+		scope = "private"
+	}
+
+	if c.MaxAge > 0 {
+		header.Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", scope, int64(c.MaxAge/time.Second)))
+	} else {
+		header.Set("Cache-Control", scope+", no-cache")
+	}
+
+	header.Set("Vary", strings.Join(append([]string{"Accept"}, c.Vary...), ", "))
+}
+
+// notModified follows RFC 7232: If-None-Match wins, If-Modified-Since is only looked at without it.
+func notModified(r *http.Request, etag string, modTime time.Time) bool {
+	if value := r.Header.Get("If-None-Match"); value != "" {
+		return etag != "" && etagMatches(value, etag)
+	}
+
+	value := r.Header.Get("If-Modified-Since")
+	if value == "" || modTime.IsZero() {
+		return false
+	}
+
+	since, err := http.ParseTime(value)
+	if err != nil {
+		return false
+	}
+
+	return !modTime.Truncate(time.Second).After(since)
+}
+
+// etagMatches uses the weak comparison, as GET conditional requests should.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
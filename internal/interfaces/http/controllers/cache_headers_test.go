@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"image-previewer/internal/domain/dto"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheHeaders_Write(t *testing.T) {
+	modTime := time.Date(2020, 9, 1, 10, 0, 0, 0, time.UTC)
+	preview := &dto.PreviewStream{ETag: `"abc"`, ModTime: modTime}
+
+	t.Run("public", func(t *testing.T) {
+		header := make(http.Header)
+		CacheHeaders{MaxAge: 24 * time.Hour}.write(header, preview)
+
+		require.Equal(t, `"abc"`, header.Get("ETag"))
+		require.Equal(t, "Tue, 01 Sep 2020 10:00:00 GMT", header.Get("Last-Modified"))
+		require.Equal(t, "public, max-age=86400", header.Get("Cache-Control"))
+		require.Equal(t, "Accept", header.Get("Vary"))
+	})
+
+	t.Run("private", func(t *testing.T) {
+		header := make(http.Header)
+		CacheHeaders{Vary: []string{"Authorization"}}.write(header, &dto.PreviewStream{})
+
+		require.Equal(t, "", header.Get("ETag"))
+		require.Equal(t, "", header.Get("Last-Modified"))
+		require.Equal(t, "private, no-cache", header.Get("Cache-Control"))
+		require.Equal(t, "Accept, Authorization", header.Get("Vary"))
+	})
+}
+
+func TestNotModified(t *testing.T) {
+	modTime := time.Date(2020, 9, 1, 10, 0, 0, 500, time.UTC)
+
+	cases := []struct {
+		name     string
+		header   map[string]string
+		expected bool
+	}{
+		{"no validators", nil, false},
+		{"matching etag", map[string]string{"If-None-Match": `"abc"`}, true},
+		{"one of etags", map[string]string{"If-None-Match": `"xyz", W/"abc"`}, true},
+		{"any etag", map[string]string{"If-None-Match": "*"}, true},
+		{"other etag", map[string]string{"If-None-Match": `"xyz"`}, false},
+		{"etag wins over date", map[string]string{
+			"If-None-Match":     `"xyz"`,
+			"If-Modified-Since": "Tue, 01 Sep 2020 10:00:00 GMT",
+		}, false},
+		{"same date", map[string]string{"If-Modified-Since": "Tue, 01 Sep 2020 10:00:00 GMT"}, true},
+		{"later date", map[string]string{"If-Modified-Since": "Wed, 02 Sep 2020 10:00:00 GMT"}, true},
+		{"earlier date", map[string]string{"If-Modified-Since": "Mon, 31 Aug 2020 10:00:00 GMT"}, false},
+		{"broken date", map[string]string{"If-Modified-Since": "yesterday"}, false},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/fill/100/100/example.com/a.jpg", nil)
+
+		for name, value := range c.header {
+			r.Header.Set(name, value)
+		}
+
+		require.Equal(t, c.expected, notModified(r, `"abc"`, modTime), c.name)
+	}
+}
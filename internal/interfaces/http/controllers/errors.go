@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"image-previewer/internal/domain"
+	"image-previewer/internal/logging"
+	"net/http"
+	"strconv"
+)
+
+var (
+	ErrInvalidWidth      = domain.NewError(domain.ErrValidation, "width should be an integer")
+	ErrInvalidHeight     = domain.NewError(domain.ErrValidation, "height should be an integer")
+	ErrInvalidBackground = domain.NewError(domain.ErrValidation, "background should be in RGB, RRGGBB or RRGGBBAA hex form")
+	ErrInvalidAutoOrient = domain.NewError(domain.ErrValidation, "auto_orient should be a boolean")
+	ErrMissingSignature  = domain.NewError(domain.ErrForbidden, "url signature is required")
+	ErrInvalidSignature  = domain.NewError(domain.ErrForbidden, "url signature does not match")
+)
+
+// relayedStatuses are origin responses the client can act upon, so they are passed through as is.
+var relayedStatuses = map[int]bool{
+	http.StatusUnauthorized:               true,
+	http.StatusForbidden:                  true,
+	http.StatusNotFound:                   true,
+	http.StatusGone:                       true,
+	http.StatusTooManyRequests:            true,
+	http.StatusUnavailableForLegalReasons: true,
+}
+
+type errorResponse struct {
+	Status int    `json:"status"`
+	Error  string `json:"error"`
+}
+
+// statusByError maps domain error kinds to response codes, anything unknown is our own fault.
+func statusByError(err error) int {
+	var upstreamErr *domain.UpstreamError
+
+	if errors.As(err, &upstreamErr) && relayedStatuses[upstreamErr.StatusCode] {
+		return upstreamErr.StatusCode
+	}
+
+	switch {
+	case errors.Is(err, domain.ErrValidation):
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, domain.ErrUpstreamNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrUpstreamTimeout), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, domain.ErrUpstreamFailure):
+		return http.StatusBadGateway
+	case errors.Is(err, domain.ErrUnsupportedMedia):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, domain.ErrPayloadTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, domain.ErrUnprocessable):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeError(ctx context.Context, w http.ResponseWriter, err error) {
+	status := statusByError(err)
+	logger := logging.FromContext(ctx)
+
+	if status >= http.StatusInternalServerError {
+		logger.Errorf("get preview failed: %s", err)
+	} else {
+		logger.Warnf("get preview rejected: %s", err)
+	}
+
+	message := err.Error()
+	if status == http.StatusInternalServerError {
+		message = http.StatusText(status)
+	}
+
+	body, _ := json.Marshal(errorResponse{
+		Status: status,
+		Error:  message,
+	})
+
+	var upstreamErr *domain.UpstreamError
+
+	if errors.As(err, &upstreamErr) {
+		for name := range upstreamErr.Header {
+			w.Header().Set(name, upstreamErr.Header.Get(name))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+
+	if _, err := w.Write(body); err != nil {
+		logger.Error(err)
+	}
+}
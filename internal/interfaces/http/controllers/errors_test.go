@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image-previewer/internal/application/handlers"
+	"image-previewer/internal/domain"
+	"image-previewer/internal/infrastructure/downloader"
+	"image-previewer/internal/infrastructure/processor"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusByError(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+	}{
+		{handlers.ErrInvalidWidth, http.StatusBadRequest},
+		{handlers.ErrEmptyURL, http.StatusBadRequest},
+		{ErrInvalidFilters, http.StatusBadRequest},
+		{ErrUnsupportedOutputFormat, http.StatusBadRequest},
+		{ErrInvalidSignature, http.StatusForbidden},
+		{fmt.Errorf("%w: host is denied", downloader.ErrSourceForbidden), http.StatusForbidden},
+		{downloader.ErrResourceNotFound, http.StatusNotFound},
+		{downloader.ErrResourceUnavailable, http.StatusBadGateway},
+		{fmt.Errorf("%w: i/o timeout", downloader.ErrResourceTimeout), http.StatusGatewayTimeout},
+		{processor.ErrUnsupportedFormat, http.StatusUnsupportedMediaType},
+		{processor.ErrInvalidImage, http.StatusUnsupportedMediaType},
+		{downloader.ErrResourceTooLarge, http.StatusRequestEntityTooLarge},
+		{processor.ErrImageTooLarge, http.StatusUnprocessableEntity},
+		{errors.New("disk is full"), http.StatusInternalServerError},
+		{context.DeadlineExceeded, http.StatusGatewayTimeout},
+		{upstreamError(http.StatusForbidden, downloader.ErrResourceUnavailable), http.StatusForbidden},
+		{upstreamError(http.StatusNotFound, downloader.ErrResourceNotFound), http.StatusNotFound},
+		{upstreamError(http.StatusBadRequest, downloader.ErrResourceUnavailable), http.StatusBadGateway},
+		{upstreamError(http.StatusInternalServerError, downloader.ErrResourceUnavailable), http.StatusBadGateway},
+	}
+
+	for _, c := range cases {
+		require.Equal(t, c.status, statusByError(c.err), c.err.Error())
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	t.Run("client error keeps message", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		writeError(context.Background(), w, handlers.ErrInvalidWidth)
+
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		require.JSONEq(t, `{"status":400,"error":"width should be greater than 0"}`, w.Body.String())
+	})
+
+	t.Run("upstream headers are relayed", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		err := upstreamError(http.StatusTooManyRequests, downloader.ErrResourceUnavailable)
+		err.Header.Set("Retry-After", "120")
+
+		writeError(context.Background(), w, err)
+
+		require.Equal(t, http.StatusTooManyRequests, w.Code)
+		require.Equal(t, "120", w.Header().Get("Retry-After"))
+	})
+
+	t.Run("internal error hides details", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		writeError(context.Background(), w, errors.New("failed to create file /var/cache/1.jpeg"))
+
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.JSONEq(t, `{"status":500,"error":"Internal Server Error"}`, w.Body.String())
+	})
+}
+
+func upstreamError(status int, kind error) *domain.UpstreamError {
+	return &domain.UpstreamError{
+		URL:        "http://ya.ru/img.jpg",
+		StatusCode: status,
+		Header:     make(http.Header),
+		Err:        kind,
+	}
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"image-previewer/internal/domain"
+	"image-previewer/internal/domain/dto"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	filtersPrefix = "filters:"
+	maxFilters    = 10
+	maxSigma      = 50
+)
+
+var ErrInvalidFilters = domain.NewError(domain.ErrValidation, "filters should look like filters:blur(3):grayscale()")
+
+var filterPattern = regexp.MustCompile(`^([a-z]+)\(([^()]*)\)$`)
+
+// splitFilters separates an optional leading "filters:..." path segment from the source url.
+func splitFilters(path string) (filters string, url string) {
+	if !strings.HasPrefix(path, filtersPrefix) {
+		return "", path
+	}
+
+	parts := strings.SplitN(path, "/", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+
+	return parts[0], parts[1]
+}
+
+func parseFilters(spec string) (dto.Filters, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	items := strings.Split(strings.TrimPrefix(spec, filtersPrefix), ":")
+	if len(items) > maxFilters {
+		return nil, ErrInvalidFilters
+	}
+
+	filters := make(dto.Filters, 0, len(items))
+
+	for _, item := range items {
+		filter, err := parseFilter(item)
+		if err != nil {
+			return nil, err
+		}
+
+		filters = append(filters, filter)
+	}
+
+	return filters, nil
+}
+
+func parseFilter(item string) (dto.Filter, error) {
+	matches := filterPattern.FindStringSubmatch(item)
+	if matches == nil {
+		return dto.Filter{}, ErrInvalidFilters
+	}
+
+	name, arg := matches[1], matches[2]
+
+	switch dto.FilterType(name) {
+	case dto.FilterBlur, dto.FilterSharpen:
+		sigma, err := strconv.ParseFloat(arg, 64)
+		if err != nil || sigma <= 0 || sigma > maxSigma {
+			return dto.Filter{}, ErrInvalidFilters
+		}
+
+		return dto.Filter{Type: dto.FilterType(name), Amount: sigma}, nil
+	case dto.FilterGrayscale:
+		if arg != "" {
+			return dto.Filter{}, ErrInvalidFilters
+		}
+
+		return dto.Filter{Type: dto.FilterGrayscale}, nil
+	case dto.FilterRotate:
+		angle, err := strconv.ParseFloat(arg, 64)
+		if err != nil || math.IsInf(angle, 0) || math.IsNaN(angle) {
+			return dto.Filter{}, ErrInvalidFilters
+		}
+
+		if angle = math.Mod(angle, 360); angle < 0 {
+			angle += 360
+		}
+
+		return dto.Filter{Type: dto.FilterRotate, Amount: angle}, nil
+	}
+
+	if name == "flip" {
+		switch arg {
+		case "", "h":
+			return dto.Filter{Type: dto.FilterFlipHorizontal}, nil
+		case "v":
+			return dto.Filter{Type: dto.FilterFlipVertical}, nil
+		}
+	}
+
+	return dto.Filter{}, ErrInvalidFilters
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"image-previewer/internal/domain/dto"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitFilters(t *testing.T) {
+	filters, url := splitFilters("filters:blur(3):grayscale()/ya.ru/img.jpg")
+
+	require.Equal(t, "filters:blur(3):grayscale()", filters)
+	require.Equal(t, "ya.ru/img.jpg", url)
+
+	filters, url = splitFilters("ya.ru/filters:blur(3)/img.jpg")
+
+	require.Equal(t, "", filters)
+	require.Equal(t, "ya.ru/filters:blur(3)/img.jpg", url)
+}
+
+func TestParseFilters(t *testing.T) {
+	t.Run("valid chain", func(t *testing.T) {
+		filters, err := parseFilters("filters:blur(3):sharpen(0.5):grayscale():rotate(-90):flip(h):flip(v)")
+
+		require.Nil(t, err)
+		require.Equal(t, dto.Filters{
+			{Type: dto.FilterBlur, Amount: 3},
+			{Type: dto.FilterSharpen, Amount: 0.5},
+			{Type: dto.FilterGrayscale},
+			{Type: dto.FilterRotate, Amount: 270},
+			{Type: dto.FilterFlipHorizontal},
+			{Type: dto.FilterFlipVertical},
+		}, filters)
+		require.Equal(t, "blur(3):sharpen(0.5):grayscale():rotate(270):flip(h):flip(v)", filters.String())
+	})
+
+	t.Run("no filters", func(t *testing.T) {
+		filters, err := parseFilters("")
+
+		require.Nil(t, err)
+		require.Nil(t, filters)
+	})
+
+	t.Run("invalid chains", func(t *testing.T) {
+		for _, spec := range []string{
+			"filters:",
+			"filters:blur",
+			"filters:blur()",
+			"filters:blur(-1)",
+			"filters:blur(500)",
+			"filters:grayscale(1)",
+			"filters:rotate(left)",
+			"filters:flip(x)",
+			"filters:sepia()",
+			"filters:blur(1):blur(1):blur(1):blur(1):blur(1):blur(1):blur(1):blur(1):blur(1):blur(1):blur(1)",
+		} {
+			_, err := parseFilters(spec)
+
+			require.Equal(t, ErrInvalidFilters, err, spec)
+		}
+	})
+}
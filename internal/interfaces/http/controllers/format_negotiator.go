@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"image-previewer/internal/domain"
+	"image-previewer/internal/domain/dto"
+	"image-previewer/internal/infrastructure/codec"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultFormat lets the processor pick png or jpeg depending on transparency.
+const DefaultFormat = dto.FormatAuto
+
+var ErrUnsupportedOutputFormat = domain.NewError(domain.ErrValidation, "requested output format is not supported")
+
+var formatAliases = map[string]dto.ImageFormat{
+	"jpg": dto.FormatJPEG,
+}
+
+type acceptedType struct {
+	mediaType string
+	quality   float64
+}
+
+type FormatNegotiator struct {
+	encoders *codec.Registry
+}
+
+// Negotiate prefers an explicit ?format= parameter, then the best encodable Accept entry,
+// and falls back to DefaultFormat for wildcards, absent or unsatisfiable Accept headers.
+func (n *FormatNegotiator) Negotiate(r *http.Request) (dto.ImageFormat, error) {
+	if value := strings.ToLower(r.URL.Query().Get("format")); value != "" {
+		format, ok := formatAliases[value]
+		if !ok {
+			format = dto.ImageFormat(value)
+		}
+
+		if format == dto.FormatAuto {
+			return format, nil
+		}
+
+		if _, err := n.encoders.Encoder(format); err != nil {
+			return "", ErrUnsupportedOutputFormat
+		}
+
+		return format, nil
+	}
+
+	for _, accepted := range parseAccept(r.Header.Get("Accept")) {
+		if accepted.mediaType == "*/*" || accepted.mediaType == "image/*" {
+			return DefaultFormat, nil
+		}
+
+		if encoder, err := n.encoders.EncoderByContentType(accepted.mediaType); err == nil {
+			return encoder.Format(), nil
+		}
+	}
+
+	return DefaultFormat, nil
+}
+
+func parseAccept(header string) []acceptedType {
+	var types []acceptedType
+
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+
+		if quality <= 0 {
+			continue
+		}
+
+		types = append(types, acceptedType{
+			mediaType: mediaType,
+			quality:   quality,
+		})
+	}
+
+	sort.SliceStable(types, func(i, j int) bool {
+		return types[i].quality > types[j].quality
+	})
+
+	return types
+}
+
+func NewFormatNegotiator(encoders *codec.Registry) *FormatNegotiator {
+	return &FormatNegotiator{
+		encoders: encoders,
+	}
+}
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"image-previewer/internal/domain/dto"
+	"image-previewer/internal/infrastructure/codec"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatNegotiator_Negotiate(t *testing.T) {
+	n := NewFormatNegotiator(codec.NewDefaultRegistry())
+
+	cases := []struct {
+		name   string
+		target string
+		accept string
+		format dto.ImageFormat
+	}{
+		{"no accept header", "/fill/1/1/ya.ru", "", dto.FormatAuto},
+		{"browser wildcard", "/fill/1/1/ya.ru", "image/avif,image/webp,image/*,*/*;q=0.8", dto.FormatAuto},
+		{"explicit png", "/fill/1/1/ya.ru", "image/png", dto.FormatPNG},
+		{"quality order", "/fill/1/1/ya.ru", "image/jpeg;q=0.5, image/png;q=0.9", dto.FormatPNG},
+		{"zero quality skipped", "/fill/1/1/ya.ru", "image/png;q=0, image/gif", dto.FormatGIF},
+		{"unsatisfiable accept", "/fill/1/1/ya.ru", "image/webp", dto.FormatAuto},
+		{"explicit auto", "/fill/1/1/ya.ru?format=auto", "image/png", dto.FormatAuto},
+		{"format parameter wins", "/fill/1/1/ya.ru?format=png", "image/jpeg", dto.FormatPNG},
+		{"format alias", "/fill/1/1/ya.ru?format=JPG", "image/png", dto.FormatJPEG},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", c.target, nil)
+		r.Header.Set("Accept", c.accept)
+
+		format, err := n.Negotiate(r)
+
+		require.Nil(t, err, c.name)
+		require.Equal(t, c.format, format, c.name)
+	}
+
+	t.Run("unsupported format parameter", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/fill/1/1/ya.ru?format=webp", nil)
+
+		format, err := n.Negotiate(r)
+
+		require.Empty(t, format)
+		require.Equal(t, ErrUnsupportedOutputFormat, err)
+	})
+}
@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"encoding/json"
+	"image-previewer/internal/infrastructure/health"
+	"image-previewer/internal/logging"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+const (
+	statusOK   = "ok"
+	statusFail = "fail"
+)
+
+type checkResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type healthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks,omitempty"`
+}
+
+type HealthController struct {
+	checks []health.Check
+}
+
+// ActionHealth only proves the process serves requests.
+func (c *HealthController) ActionHealth(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, healthResponse{Status: statusOK})
+}
+
+// ActionReady runs every check and answers 503 if any of them fails.
+func (c *HealthController) ActionReady(w http.ResponseWriter, r *http.Request) {
+	resp := healthResponse{
+		Status: statusOK,
+		Checks: make(map[string]checkResult, len(c.checks)),
+	}
+	status := http.StatusOK
+
+	for _, check := range c.checks {
+		if err := check.Run(r.Context()); err != nil {
+			logging.FromContext(r.Context()).Warnf("readiness check %s failed: %s", check.Name, err)
+
+			resp.Checks[check.Name] = checkResult{Status: statusFail, Error: err.Error()}
+			resp.Status = statusFail
+			status = http.StatusServiceUnavailable
+
+			continue
+		}
+
+		resp.Checks[check.Name] = checkResult{Status: statusOK}
+	}
+
+	writeHealth(w, status, resp)
+}
+
+func writeHealth(w http.ResponseWriter, status int, resp healthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		zap.S().Errorf("failed to write health response: %s", err)
+	}
+}
+
+func NewHealthController(checks ...health.Check) *HealthController {
+	return &HealthController{
+		checks: checks,
+	}
+}
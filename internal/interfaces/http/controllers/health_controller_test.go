@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"image-previewer/internal/infrastructure/health"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthController(t *testing.T) {
+	passing := health.Check{Name: "passing", Run: func(ctx context.Context) error { return nil }}
+	failing := health.Check{Name: "failing", Run: func(ctx context.Context) error { return errors.New("disk is full") }}
+
+	t.Run("healthz ignores checks", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		NewHealthController(failing).ActionHealth(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		require.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+	})
+
+	t.Run("ready", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		NewHealthController(passing).ActionReady(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		require.JSONEq(t, `{"status":"ok","checks":{"passing":{"status":"ok"}}}`, w.Body.String())
+	})
+
+	t.Run("not ready", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		NewHealthController(passing, failing).ActionReady(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		require.Equal(t, http.StatusServiceUnavailable, w.Code)
+		require.JSONEq(
+			t,
+			`{"status":"fail","checks":{"passing":{"status":"ok"},"failing":{"status":"fail","error":"disk is full"}}}`,
+			w.Body.String(),
+		)
+	})
+}
@@ -1,81 +1,198 @@
 package controllers
 
 import (
-	"bytes"
 	"image-previewer/internal/application/handlers"
 	"image-previewer/internal/application/queries"
 	"image-previewer/internal/domain"
 	"image-previewer/internal/domain/dto"
-	"image/jpeg"
+	"image-previewer/internal/infrastructure"
+	"image-previewer/internal/infrastructure/codec"
+	"image-previewer/internal/logging"
+	"image/color"
+	"io"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
-	"go.uber.org/zap"
 )
 
 type ImagePreviewController struct {
-	handler *handlers.ImagePreviewQueryHandler
+	handler    *handlers.ImagePreviewQueryHandler
+	negotiator *FormatNegotiator
+	background color.NRGBA
+	// signer is nil when url signing is disabled
+	signer *infrastructure.URLSigner
+	cache  CacheHeaders
 }
 
 func (c *ImagePreviewController) ActionGet(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
+	q, err := c.buildQuery(r)
+	if err != nil {
+		writeError(r.Context(), w, err)
 
-	width, err := strconv.Atoi(vars["width"])
+		return
+	}
+
+	preview, err := c.handler.Handle(r.Context(), q)
 	if err != nil {
-		zap.S().Warnf("invalid width value", err)
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(r.Context(), w, err)
+
+		return
+	}
+
+	defer preview.Content.Close()
+
+	c.cache.write(w.Header(), preview)
+
+	if notModified(r, preview.ETag, preview.ModTime) {
+		w.WriteHeader(http.StatusNotModified)
 
 		return
 	}
 
+	w.Header().Set("Content-Type", preview.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(preview.Size, 10))
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := io.Copy(w, preview.Content); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+	}
+}
+
+func (c *ImagePreviewController) buildQuery(r *http.Request) (queries.ImagePreviewQuery, error) {
+	vars := mux.Vars(r)
+	params := r.URL.Query()
+
+	if err := c.checkSignature(vars, params); err != nil {
+		return queries.ImagePreviewQuery{}, err
+	}
+
+	width, err := strconv.Atoi(vars["width"])
+	if err != nil {
+		return queries.ImagePreviewQuery{}, ErrInvalidWidth
+	}
+
 	height, err := strconv.Atoi(vars["height"])
 	if err != nil {
-		zap.S().Warnf("invalid height value", err)
-		w.WriteHeader(http.StatusBadRequest)
+		return queries.ImagePreviewQuery{}, ErrInvalidHeight
+	}
 
-		return
+	filtersSpec, sourceURL := splitFilters(vars["url"])
+
+	filters, err := parseFilters(filtersSpec)
+	if err != nil {
+		return queries.ImagePreviewQuery{}, err
+	}
+
+	background := c.background
+
+	if value := params.Get("background"); value != "" {
+		if background, err = dto.ParseHexColor(value); err != nil {
+			return queries.ImagePreviewQuery{}, ErrInvalidBackground
+		}
+	}
+
+	autoOrient := true
+
+	if value := params.Get("auto_orient"); value != "" {
+		if autoOrient, err = strconv.ParseBool(value); err != nil {
+			return queries.ImagePreviewQuery{}, ErrInvalidAutoOrient
+		}
 	}
 
-	img, err := c.handler.Handle(queries.ImagePreviewQuery{
-		URL:     vars["url"],
+	format, err := c.negotiator.Negotiate(r)
+	if err != nil {
+		return queries.ImagePreviewQuery{}, err
+	}
+
+	return queries.ImagePreviewQuery{
+		URL:     sourceURL,
 		Headers: domain.RequestHeaders(r.Header),
-		Dimensions: dto.ImageDimensions{
-			Width:  width,
-			Height: height,
+		Transformation: dto.Transformation{
+			Mode: dto.ResizeMode(vars["mode"]),
+			Dimensions: dto.ImageDimensions{
+				Width:  width,
+				Height: height,
+			},
+			Gravity:           dto.Gravity(params.Get("gravity")),
+			Background:        background,
+			Filters:           filters,
+			IgnoreOrientation: !autoOrient,
 		},
-	})
-	if err != nil {
-		zap.S().Errorf("get preview query handle failed: %s", err)
+		Format: format,
+	}, nil
+}
 
-		w.WriteHeader(http.StatusInternalServerError)
+// signedOptions are the query options that change the preview and its cache key, the signature covers them
+// so a signed path can't be varied into endless distinct renders.
+var signedOptions = []string{"auto_orient", "background", "format", "gravity"}
 
-		return
+// checkSignature lets anything through unless signing is enabled, then every path has to carry
+// the signature of the operation, dimensions, source url and options that follow it.
+func (c *ImagePreviewController) checkSignature(vars map[string]string, params url.Values) error {
+	if c.signer == nil {
+		return nil
 	}
 
-	buf := new(bytes.Buffer)
+	signature, ok := vars["signature"]
+	if !ok {
+		return ErrMissingSignature
+	}
 
-	if err := jpeg.Encode(buf, img, nil); err != nil {
-		zap.S().Error(err)
-		w.WriteHeader(http.StatusInternalServerError)
+	path := strings.Join([]string{vars["mode"], vars["width"], vars["height"], vars["url"]}, "/")
 
-		return
+	if !c.signer.Verify(SigningString(path, params), signature) {
+		return ErrInvalidSignature
 	}
 
-	if _, err := w.Write(buf.Bytes()); err != nil {
-		zap.S().Error(err)
-		w.WriteHeader(http.StatusInternalServerError)
+	return nil
+}
+
+// SigningString is what the signature covers: the path after it as the router sees it, that is
+// unescaped, e.g. fill/300/200/example.com/a.jpg, followed by the signed options sorted by name.
+func SigningString(path string, params url.Values) string {
+	options := make(url.Values)
 
-		return
+	for _, name := range signedOptions {
+		if value := params.Get(name); value != "" {
+			options.Set(name, value)
+		}
 	}
 
-	w.Header().Set("Content-Type", "image/jpeg")
-	w.Header().Set("Content-Length", strconv.Itoa(len(buf.Bytes())))
-	w.WriteHeader(http.StatusOK)
+	if len(options) == 0 {
+		return path
+	}
+
+	return path + "?" + options.Encode()
+}
+
+// SignPreviewPath turns a preview path like /thumbnail/300/200/example.com/a.jpg?background=000 into its
+// signed form /{signature}/thumbnail/300/200/example.com/a.jpg?background=000.
+func SignPreviewPath(signer *infrastructure.URLSigner, path string) (string, error) {
+	path = "/" + strings.TrimPrefix(path, "/")
+
+	u, err := url.Parse(path)
+	if err != nil {
+		return "", err
+	}
+
+	return "/" + signer.Sign(SigningString(strings.TrimPrefix(u.Path, "/"), u.Query())) + path, nil
 }
 
-func NewImagePreviewController(h *handlers.ImagePreviewQueryHandler) *ImagePreviewController {
+func NewImagePreviewController(
+	h *handlers.ImagePreviewQueryHandler,
+	codecs *codec.Registry,
+	background color.NRGBA,
+	signer *infrastructure.URLSigner,
+	cache CacheHeaders,
+) *ImagePreviewController {
 	return &ImagePreviewController{
-		handler: h,
+		handler:    h,
+		negotiator: NewFormatNegotiator(codecs),
+		background: background,
+		signer:     signer,
+		cache:      cache,
 	}
 }
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"image-previewer/internal/infrastructure"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImagePreviewController_CheckSignature(t *testing.T) {
+	signer := infrastructure.NewURLSigner("secret")
+	vars := map[string]string{
+		"mode":   "fill",
+		"width":  "300",
+		"height": "200",
+		"url":    "www.audubon.org/sites/default/files/owl.jpg",
+	}
+
+	t.Run("signing disabled", func(t *testing.T) {
+		c := &ImagePreviewController{}
+
+		require.Nil(t, c.checkSignature(vars, nil))
+	})
+
+	t.Run("missing signature", func(t *testing.T) {
+		c := &ImagePreviewController{signer: signer}
+
+		require.Equal(t, ErrMissingSignature, c.checkSignature(vars, nil))
+	})
+
+	t.Run("valid signature", func(t *testing.T) {
+		c := &ImagePreviewController{signer: signer}
+		signed := map[string]string{"signature": signer.Sign("fill/300/200/www.audubon.org/sites/default/files/owl.jpg")}
+
+		for k, v := range vars {
+			signed[k] = v
+		}
+
+		require.Nil(t, c.checkSignature(signed, nil))
+		require.Nil(t, c.checkSignature(signed, url.Values{"filter": {"unsigned"}}))
+		require.Equal(t, ErrInvalidSignature, c.checkSignature(signed, url.Values{"format": {"png"}}))
+
+		signed["width"] = "3000"
+
+		require.Equal(t, ErrInvalidSignature, c.checkSignature(signed, nil))
+	})
+}
+
+func TestSigningString(t *testing.T) {
+	params := url.Values{
+		"gravity":     {"north"},
+		"background":  {"ff0000"},
+		"format":      {""},
+		"unsupported": {"1"},
+	}
+
+	require.Equal(t, "fill/1/1/a.jpg", SigningString("fill/1/1/a.jpg", nil))
+	require.Equal(t, "fill/1/1/a.jpg?background=ff0000&gravity=north", SigningString("fill/1/1/a.jpg", params))
+}
+
+func TestSignPreviewPath(t *testing.T) {
+	signer := infrastructure.NewURLSigner("secret")
+	router := mux.NewRouter()
+	router.HandleFunc("/{signature}/{mode}/{width}/{height}/{url:.*}", func(w http.ResponseWriter, r *http.Request) {
+		c := &ImagePreviewController{signer: signer}
+
+		if err := c.checkSignature(mux.Vars(r), r.URL.Query()); err != nil {
+			writeError(r.Context(), w, err)
+		}
+	})
+
+	serve := func(path string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		return w.Code
+	}
+
+	t.Run("options", func(t *testing.T) {
+		signed, err := SignPreviewPath(signer, "/thumbnail/300/200/example.com/owl.jpg?background=ff0000")
+		require.Nil(t, err)
+
+		require.Equal(t, http.StatusOK, serve(signed))
+		require.Equal(t, http.StatusForbidden, serve(signed[:len(signed)-6]+"00ff00"))
+		require.Equal(t, http.StatusForbidden, serve(signed+"&format=png"))
+	})
+
+	t.Run("escaped url", func(t *testing.T) {
+		signed, err := SignPreviewPath(signer, "fill/300/200/example.com/my%20owl.jpg")
+		require.Nil(t, err)
+
+		require.Equal(t, http.StatusOK, serve(signed))
+	})
+}
+
+func TestImagePreviewController_ActionGet_ChangedOption(t *testing.T) {
+	signer := infrastructure.NewURLSigner("secret")
+	c := &ImagePreviewController{signer: signer}
+	vars := map[string]string{
+		"signature": signer.Sign("thumbnail/300/200/example.com/owl.jpg?background=ff0000"),
+		"mode":      "thumbnail",
+		"width":     "300",
+		"height":    "200",
+		"url":       "example.com/owl.jpg",
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/thumbnail/300/200/example.com/owl.jpg?background=00ff00", nil)
+	w := httptest.NewRecorder()
+
+	c.ActionGet(w, mux.SetURLVars(r, vars))
+
+	require.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"image-previewer/internal/logging"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID keeps client supplied ids short and safe to put into logs and headers.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// responseRecorder remembers what was written for the access log.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+
+	return n, err
+}
+
+// AccessLog assigns every request an id, reusing a valid X-Request-ID of the client, puts a logger
+// carrying it into the context and writes one line per request once it is served.
+func AccessLog() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			id := requestID(r)
+			logger := zap.S().With("request_id", id)
+			ctx := logging.NewContext(r.Context(), id, logger)
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+
+			w.Header().Set(RequestIDHeader, id)
+			next.ServeHTTP(rec, r.WithContext(ctx))
+
+			fields := []interface{}{
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", rec.status,
+				"bytes", rec.bytes,
+				"latency", time.Since(start),
+			}
+
+			logger.Infow("request served", append(fields, logging.Fields(ctx)...)...)
+		})
+	}
+}
+
+func requestID(r *http.Request) string {
+	if id := r.Header.Get(RequestIDHeader); validRequestID.MatchString(id) {
+		return id
+	}
+
+	b := make([]byte, 16)
+
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+
+	return hex.EncodeToString(b)
+}
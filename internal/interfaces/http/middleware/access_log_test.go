@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"image-previewer/internal/logging"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestAccessLog(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	defer zap.ReplaceGlobals(zap.New(core))()
+
+	handler := AccessLog()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logging.Annotate(r.Context(), "cache", "miss")
+		logging.FromContext(r.Context()).Info("handling")
+
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte("failed"))
+	}))
+
+	t.Run("generated id", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fill/1/1/example.com/a.jpg", nil))
+
+		id := w.Header().Get(RequestIDHeader)
+		require.Len(t, id, 32)
+
+		entries := logs.TakeAll()
+		require.Len(t, entries, 2)
+		require.Equal(t, id, entries[0].ContextMap()["request_id"])
+
+		fields := entries[1].ContextMap()
+		require.Equal(t, "request served", entries[1].Message)
+		require.Equal(t, id, fields["request_id"])
+		require.Equal(t, "GET", fields["method"])
+		require.Equal(t, "/fill/1/1/example.com/a.jpg", fields["path"])
+		require.Equal(t, int64(http.StatusBadGateway), fields["status"])
+		require.Equal(t, int64(6), fields["bytes"])
+		require.Equal(t, "miss", fields["cache"])
+		require.Contains(t, fields, "latency")
+	})
+
+	t.Run("propagated id", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		r.Header.Set(RequestIDHeader, "lb-42")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		require.Equal(t, "lb-42", w.Header().Get(RequestIDHeader))
+		require.Equal(t, "lb-42", logs.TakeAll()[1].ContextMap()["request_id"])
+	})
+
+	t.Run("invalid id is replaced", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		r.Header.Set(RequestIDHeader, "bad id\nwith newline")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		require.Len(t, w.Header().Get(RequestIDHeader), 32)
+		logs.TakeAll()
+	})
+}
@@ -0,0 +1,118 @@
+package logging
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+type ctxKey struct{}
+
+// requestLog is the request scoped logger together with fields collected for the access log.
+type requestLog struct {
+	id     string
+	logger *zap.SugaredLogger
+	mux    sync.Mutex
+	fields map[string]interface{}
+}
+
+// NewContext attaches the request id and a logger carrying it, log lines written with FromContext
+// carry its fields.
+func NewContext(ctx context.Context, id string, logger *zap.SugaredLogger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, &requestLog{
+		id:     id,
+		logger: logger,
+		fields: make(map[string]interface{}),
+	})
+}
+
+// FromContext returns the request scoped logger, or the global one outside of a request.
+func FromContext(ctx context.Context) *zap.SugaredLogger {
+	if l, ok := ctx.Value(ctxKey{}).(*requestLog); ok {
+		return l.logger
+	}
+
+	return zap.S()
+}
+
+// RequestID is the id of the request, empty outside of a request.
+func RequestID(ctx context.Context) string {
+	if l, ok := ctx.Value(ctxKey{}).(*requestLog); ok {
+		return l.id
+	}
+
+	return ""
+}
+
+// Fork keeps the logger of the request but starts an empty set of annotations, for work shared
+// by several requests. Merge hands what was collected to each of them.
+func Fork(ctx context.Context) context.Context {
+	l, ok := ctx.Value(ctxKey{}).(*requestLog)
+	if !ok {
+		return ctx
+	}
+
+	return NewContext(ctx, l.id, l.logger)
+}
+
+// Merge copies the annotations of src into dst, values of src win.
+func Merge(dst, src context.Context) {
+	from, ok := src.Value(ctxKey{}).(*requestLog)
+	if !ok {
+		return
+	}
+
+	if to, ok := dst.Value(ctxKey{}).(*requestLog); !ok || to == from {
+		return
+	}
+
+	from.mux.Lock()
+	defer from.mux.Unlock()
+
+	for key, value := range from.fields {
+		Annotate(dst, key, value)
+	}
+}
+
+// Annotate records a field for the access log of the request, a later value for the same key wins.
+// It's a no-op outside of a request.
+func Annotate(ctx context.Context, key string, value interface{}) {
+	l, ok := ctx.Value(ctxKey{}).(*requestLog)
+	if !ok {
+		return
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	l.fields[key] = value
+}
+
+// Fields returns the annotations as zap key-value pairs, ordered by key.
+func Fields(ctx context.Context) []interface{} {
+	l, ok := ctx.Value(ctxKey{}).(*requestLog)
+	if !ok {
+		return nil
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	keys := make([]string, 0, len(l.fields))
+
+	for key := range l.fields {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	fields := make([]interface{}, 0, 2*len(keys))
+
+	for _, key := range keys {
+		fields = append(fields, key, l.fields[key])
+	}
+
+	return fields
+}
@@ -0,0 +1,57 @@
+package logging
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestFromContext(t *testing.T) {
+	require.Equal(t, zap.S(), FromContext(context.Background()))
+
+	logger := zap.NewNop().Sugar()
+
+	ctx := NewContext(context.Background(), "req-1", logger)
+
+	require.Equal(t, logger, FromContext(ctx))
+	require.Equal(t, "req-1", RequestID(ctx))
+	require.Equal(t, "", RequestID(context.Background()))
+}
+
+func TestAnnotate(t *testing.T) {
+	Annotate(context.Background(), "cache", "hit")
+	require.Nil(t, Fields(context.Background()))
+
+	ctx := NewContext(context.Background(), "req-1", zap.NewNop().Sugar())
+
+	Annotate(ctx, "upstream_status", 304)
+	Annotate(ctx, "cache", "stale")
+	Annotate(ctx, "cache", "hit")
+
+	require.Equal(t, []interface{}{"cache", "hit", "upstream_status", 304}, Fields(ctx))
+}
+
+func TestForkAndMerge(t *testing.T) {
+	logger := zap.NewNop().Sugar()
+	ctx := NewContext(context.Background(), "req-1", logger)
+	Annotate(ctx, "cache", "miss")
+
+	shared := Fork(ctx)
+	Annotate(shared, "upstream_status", 200)
+
+	require.Equal(t, logger, FromContext(shared))
+	require.Equal(t, "req-1", RequestID(shared))
+	require.Equal(t, []interface{}{"upstream_status", 200}, Fields(shared))
+	require.Equal(t, []interface{}{"cache", "miss"}, Fields(ctx))
+
+	joined := NewContext(context.Background(), "req-2", logger)
+
+	Merge(ctx, shared)
+	Merge(joined, shared)
+
+	require.Equal(t, []interface{}{"cache", "miss", "upstream_status", 200}, Fields(ctx))
+	require.Equal(t, []interface{}{"upstream_status", 200}, Fields(joined))
+	require.Equal(t, context.Background(), Fork(context.Background()))
+}
@@ -0,0 +1,20 @@
+package internal
+
+import (
+	"errors"
+	"image-previewer/internal/infrastructure"
+	"image-previewer/internal/interfaces/http/controllers"
+
+	"github.com/spf13/viper"
+)
+
+// SignPath turns a preview path like /fill/300/200/example.com/a.jpg, optionally followed by
+// its query options, into its signed form /{signature}/fill/300/200/example.com/a.jpg using the configured key.
+func SignPath(path string) (string, error) {
+	key := viper.GetString("app.url_signature_key")
+	if key == "" {
+		return "", errors.New("url_signature_key is not configured")
+	}
+
+	return controllers.SignPreviewPath(infrastructure.NewURLSigner(key), path)
+}
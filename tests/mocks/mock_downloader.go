@@ -5,7 +5,7 @@
 package mocks
 
 import (
-	image "image"
+	context "context"
 	domain "image-previewer/internal/domain"
 	dto "image-previewer/internal/domain/dto"
 	reflect "reflect"
@@ -37,16 +37,16 @@ func (m *MockDownloader) EXPECT() *MockDownloaderMockRecorder {
 }
 
 // Download mocks base method
-func (m *MockDownloader) Download(arg0 string, arg1 dto.ImageDimensions, arg2 domain.RequestHeaders) (image.Image, error) {
+func (m *MockDownloader) Download(arg0 context.Context, arg1 string, arg2 domain.RequestHeaders, arg3 dto.OriginCache) (*dto.SourceImage, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Download", arg0, arg1, arg2)
-	ret0, _ := ret[0].(image.Image)
+	ret := m.ctrl.Call(m, "Download", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*dto.SourceImage)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Download indicates an expected call of Download
-func (mr *MockDownloaderMockRecorder) Download(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockDownloaderMockRecorder) Download(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Download", reflect.TypeOf((*MockDownloader)(nil).Download), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Download", reflect.TypeOf((*MockDownloader)(nil).Download), arg0, arg1, arg2, arg3)
 }
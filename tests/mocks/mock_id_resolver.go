@@ -36,15 +36,15 @@ func (m *MockImageIDResolver) EXPECT() *MockImageIDResolverMockRecorder {
 }
 
 // ResolveImageID mocks base method
-func (m *MockImageIDResolver) ResolveImageID(arg0 string, arg1 dto.ImageDimensions) domain.ImageID {
+func (m *MockImageIDResolver) ResolveImageID(arg0 string, arg1 domain.RequestHeaders, arg2 dto.Transformation, arg3 dto.ImageFormat) domain.ImageID {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ResolveImageID", arg0, arg1)
+	ret := m.ctrl.Call(m, "ResolveImageID", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(domain.ImageID)
 	return ret0
 }
 
 // ResolveImageID indicates an expected call of ResolveImageID
-func (mr *MockImageIDResolverMockRecorder) ResolveImageID(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockImageIDResolverMockRecorder) ResolveImageID(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResolveImageID", reflect.TypeOf((*MockImageIDResolver)(nil).ResolveImageID), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResolveImageID", reflect.TypeOf((*MockImageIDResolver)(nil).ResolveImageID), arg0, arg1, arg2, arg3)
 }
@@ -0,0 +1,81 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: image-previewer/internal/domain (interfaces: ImageProcessor)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	image "image"
+	dto "image-previewer/internal/domain/dto"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockImageProcessor is a mock of ImageProcessor interface
+type MockImageProcessor struct {
+	ctrl     *gomock.Controller
+	recorder *MockImageProcessorMockRecorder
+}
+
+// MockImageProcessorMockRecorder is the mock recorder for MockImageProcessor
+type MockImageProcessorMockRecorder struct {
+	mock *MockImageProcessor
+}
+
+// NewMockImageProcessor creates a new mock instance
+func NewMockImageProcessor(ctrl *gomock.Controller) *MockImageProcessor {
+	mock := &MockImageProcessor{ctrl: ctrl}
+	mock.recorder = &MockImageProcessorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockImageProcessor) EXPECT() *MockImageProcessorMockRecorder {
+	return m.recorder
+}
+
+// Decode mocks base method
+func (m *MockImageProcessor) Decode(arg0 *dto.SourceImage, arg1 bool) (image.Image, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Decode", arg0, arg1)
+	ret0, _ := ret[0].(image.Image)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Decode indicates an expected call of Decode
+func (mr *MockImageProcessorMockRecorder) Decode(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Decode", reflect.TypeOf((*MockImageProcessor)(nil).Decode), arg0, arg1)
+}
+
+// Encode mocks base method
+func (m *MockImageProcessor) Encode(arg0 image.Image, arg1 dto.ImageFormat) (*dto.Preview, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Encode", arg0, arg1)
+	ret0, _ := ret[0].(*dto.Preview)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Encode indicates an expected call of Encode
+func (mr *MockImageProcessorMockRecorder) Encode(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Encode", reflect.TypeOf((*MockImageProcessor)(nil).Encode), arg0, arg1)
+}
+
+// Transform mocks base method
+func (m *MockImageProcessor) Transform(arg0 image.Image, arg1 dto.Transformation) (image.Image, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Transform", arg0, arg1)
+	ret0, _ := ret[0].(image.Image)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Transform indicates an expected call of Transform
+func (mr *MockImageProcessorMockRecorder) Transform(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Transform", reflect.TypeOf((*MockImageProcessor)(nil).Transform), arg0, arg1)
+}
@@ -5,8 +5,9 @@
 package mocks
 
 import (
-	image "image"
+	context "context"
 	domain "image-previewer/internal/domain"
+	dto "image-previewer/internal/domain/dto"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -36,31 +37,46 @@ func (m *MockPreviewRepository) EXPECT() *MockPreviewRepositoryMockRecorder {
 }
 
 // Add mocks base method
-func (m *MockPreviewRepository) Add(arg0 domain.ImageID, arg1 image.Image) (bool, error) {
+func (m *MockPreviewRepository) Add(arg0 context.Context, arg1 domain.ImageID, arg2 *dto.Preview) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Add", arg0, arg1)
+	ret := m.ctrl.Call(m, "Add", arg0, arg1, arg2)
 	ret0, _ := ret[0].(bool)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Add indicates an expected call of Add
-func (mr *MockPreviewRepositoryMockRecorder) Add(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockPreviewRepositoryMockRecorder) Add(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Add", reflect.TypeOf((*MockPreviewRepository)(nil).Add), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Add", reflect.TypeOf((*MockPreviewRepository)(nil).Add), arg0, arg1, arg2)
 }
 
 // FindOne mocks base method
-func (m *MockPreviewRepository) FindOne(arg0 domain.ImageID) (image.Image, error) {
+func (m *MockPreviewRepository) FindOne(arg0 context.Context, arg1 domain.ImageID) (*dto.PreviewStream, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindOne", arg0)
-	ret0, _ := ret[0].(image.Image)
+	ret := m.ctrl.Call(m, "FindOne", arg0, arg1)
+	ret0, _ := ret[0].(*dto.PreviewStream)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindOne indicates an expected call of FindOne
-func (mr *MockPreviewRepositoryMockRecorder) FindOne(arg0 interface{}) *gomock.Call {
+func (mr *MockPreviewRepositoryMockRecorder) FindOne(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOne", reflect.TypeOf((*MockPreviewRepository)(nil).FindOne), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOne", reflect.TypeOf((*MockPreviewRepository)(nil).FindOne), arg0, arg1)
+}
+
+// Refresh mocks base method
+func (m *MockPreviewRepository) Refresh(arg0 context.Context, arg1 domain.ImageID, arg2 dto.OriginCache) (*dto.Preview, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Refresh", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*dto.Preview)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Refresh indicates an expected call of Refresh
+func (mr *MockPreviewRepositoryMockRecorder) Refresh(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Refresh", reflect.TypeOf((*MockPreviewRepository)(nil).Refresh), arg0, arg1, arg2)
 }